@@ -0,0 +1,146 @@
+// Command audit verifies and queries the access audit log written by the
+// server with -audit-log.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"user-service-module/internal/audit"
+)
+
+// Exit codes.
+const (
+	exitFailure = 1
+	exitUsage   = 64
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "query":
+		return runQuery(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		usage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+  audit verify <file>
+        check that no record of the log was modified, removed or reordered
+  audit query <file> [-subject S] [-tenant T] [-method M] [-user ID] [-since T] [-until T]
+        print the matching records as JSON lines
+`)
+}
+
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		usage(stderr)
+		return exitUsage
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	defer f.Close()
+
+	summary, err := audit.Verify(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: chain broken at %v\n", args[0], err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "ok: %d records, head %s\n", summary.Records, summary.Head)
+	return 0
+}
+
+func runQuery(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	path := args[0]
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var filter audit.Filter
+	fs.StringVar(&filter.Subject, "subject", "", "only records of this caller")
+	fs.StringVar(&filter.Tenant, "tenant", "", "only records of calls acting for this tenant")
+	fs.StringVar(&filter.Method, "method", "", "only records of this method, such as GetUser")
+	user := fs.String("user", "", "only records that returned this user ID")
+	since := fs.String("since", "", "only records at or after this RFC 3339 time")
+	until := fs.String("until", "", "only records before this RFC 3339 time")
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "query: unexpected argument %q\n", fs.Arg(0))
+		return exitUsage
+	}
+
+	if *user != "" {
+		id, err := strconv.ParseUint(*user, 10, 32)
+		if err != nil {
+			fmt.Fprintf(stderr, "query: invalid -user %q\n", *user)
+			return exitUsage
+		}
+		filter.UserID = uint32(id)
+	}
+	for _, t := range []struct {
+		flag, value string
+		dst         *time.Time
+	}{{"since", *since, &filter.Since}, {"until", *until, &filter.Until}} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			fmt.Fprintf(stderr, "query: invalid -%s %q, want an RFC 3339 time such as 2024-05-01T12:00:00Z\n", t.flag, t.value)
+			return exitUsage
+		}
+		*t.dst = parsed
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(stdout)
+	err = audit.Read(f, func(r audit.Record) error {
+		if !filter.Match(r) {
+			return nil
+		}
+		return enc.Encode(r)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return exitFailure
+	}
+	return 0
+}
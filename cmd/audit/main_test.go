@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"user-service-module/internal/audit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	for _, r := range []audit.Record{
+		{Subject: "alice", Method: "/proto.UserService/GetUser", Code: "OK", UserIDs: []uint32{1}},
+		{Subject: "bob", Tenant: "acme", Method: "/proto.UserService/ListUsers", Code: "OK", UserIDs: []uint32{1, 2}},
+		{Subject: "alice", Method: "/proto.UserService/GetUser", Code: "OK", UserIDs: []uint32{2}},
+	} {
+		if _, err := log.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	path := writeLog(t)
+
+	var stdout bytes.Buffer
+	assert.Equal(t, 0, run([]string{"verify", path}, &stdout, io.Discard))
+	assert.True(t, strings.HasPrefix(stdout.String(), "ok: 3 records, head "))
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(`"bob"`), []byte(`"eve"`), 1), 0o600)
+	var stderr bytes.Buffer
+	assert.Equal(t, exitFailure, run([]string{"verify", path}, io.Discard, &stderr))
+	assert.Contains(t, stderr.String(), "line 2: record 2 was modified")
+}
+
+func TestQuery(t *testing.T) {
+	path := writeLog(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantSeqs []uint64
+	}{
+		{"should print every record", nil, 0, []uint64{1, 2, 3}},
+		{"should filter by subject", []string{"-subject", "alice"}, 0, []uint64{1, 3}},
+		{"should filter by tenant", []string{"-tenant", "acme"}, 0, []uint64{2}},
+		{"should filter by the default tenant", []string{"-tenant", "default"}, 0, []uint64{1, 3}},
+		{"should filter by user", []string{"-user", "2"}, 0, []uint64{2, 3}},
+		{"should combine filters", []string{"-subject", "alice", "-method", "GetUser", "-user", "1"}, 0, []uint64{1}},
+		{"should reject an invalid user", []string{"-user", "x"}, exitUsage, nil},
+		{"should reject an invalid time", []string{"-since", "yesterday"}, exitUsage, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			code := run(append([]string{"query", path}, tt.args...), &stdout, io.Discard)
+			assert.Equal(t, tt.wantCode, code)
+
+			var seqs []uint64
+			audit.Read(&stdout, func(r audit.Record) error {
+				seqs = append(seqs, r.Seq)
+				return nil
+			})
+			assert.Equal(t, tt.wantSeqs, seqs)
+		})
+	}
+}
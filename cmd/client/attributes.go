@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	pb "user-service-module/proto/user/userpb"
+)
+
+// attrFlag collects repeated -attr key=value flags.
+type attrFlag map[string]string
+
+func (a attrFlag) String() string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + a[k]
+	}
+	return strings.Join(keys, ",")
+}
+
+func (a attrFlag) Set(s string) error {
+	key, value, found := strings.Cut(s, "=")
+	if !found || key == "" {
+		return fmt.Errorf("want key=value, got %q", s)
+	}
+	a[key] = value
+	return nil
+}
+
+func runAttributes(ctx context.Context, e *env, args []string) error {
+	if len(args) > 0 {
+		return usagef("attributes takes no arguments")
+	}
+
+	defs, err := e.client.ListAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	return e.printer.printAttributes(defs)
+}
+
+func runRegisterAttribute(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("register-attribute takes the attribute key before any flags")
+	}
+
+	fs := newFlagSet("register-attribute")
+	typ := fs.String("type", "", "attribute type: "+attributeTypeNames())
+	description := fs.String("description", "", "what the attribute holds")
+	indexed := fs.Bool("indexed", false, "make the attribute searchable")
+	maxLength := fs.Uint("max-length", 0, "string: the most characters a value may have")
+	pattern := fs.String("pattern", "", "string: a regular expression whole values must match")
+	minValue := fs.Int64("min", 0, "int: the smallest allowed value")
+	maxValue := fs.Int64("max", 0, "int: the largest allowed value")
+	values := fs.String("values", "", "enum: comma-separated allowed values")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	t, found := pb.AttributeDefinition_Type_value[strings.ToUpper(*typ)]
+	if !found || t == int32(pb.AttributeDefinition_TYPE_UNSPECIFIED) {
+		return usagef("invalid attribute type %q, want one of %s", *typ, attributeTypeNames())
+	}
+	def := &pb.AttributeDefinition{
+		Key:         args[0],
+		Type:        pb.AttributeDefinition_Type(t),
+		Description: *description,
+		Indexed:     *indexed,
+		MaxLength:   uint32(*maxLength),
+		Pattern:     *pattern,
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min":
+			def.Min = minValue
+		case "max":
+			def.Max = maxValue
+		}
+	})
+	if *values != "" {
+		def.Values = strings.Split(*values, ",")
+	}
+
+	registered, err := e.client.RegisterAttribute(ctx, def)
+	if err != nil {
+		return err
+	}
+	return e.printer.printAttributes([]*pb.AttributeDefinition{registered})
+}
+
+func runDeleteAttribute(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return usagef("delete-attribute takes exactly one attribute key")
+	}
+
+	if err := e.client.DeleteAttribute(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "deleted attribute %s\n", args[0])
+	return nil
+}
+
+func attributeTypeNames() string {
+	values := pb.AttributeDefinition_TYPE_UNSPECIFIED.Descriptor().Values()
+	names := make([]string, 0, values.Len()-1)
+	for i := 1; i < values.Len(); i++ {
+		names = append(names, string(values.Get(i).Name()))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service-module/internal/bench"
+)
+
+func runBench(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("bench")
+	mix := fs.String("mix", "get=6,list=3,search=1", "operation weights; operations are get, list, search, create, update and delete")
+	duration := fs.Duration("d", 10*time.Second, "how long to send requests")
+	concurrency := fs.Int("c", 10, "number of requests in flight")
+	qps := fs.Float64("qps", 0, "total request rate; 0 sends as fast as -c allows")
+	ids := fs.String("ids", "1,2,3", "user IDs read by get and list")
+	listSize := fs.Int("list-size", 3, "number of IDs per list request")
+	cities := fs.String("cities", "LA,NY", "cities searched for by search")
+	seed := fs.Int64("seed", 1, "seed for the sequence of operations")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if e.printer.format != "table" && e.printer.format != "json" {
+		return usagef("bench: output format must be table or json")
+	}
+
+	cfg := bench.Config{
+		Duration:    *duration,
+		Concurrency: *concurrency,
+		QPS:         *qps,
+		ListSize:    *listSize,
+		Cities:      splitList(*cities),
+		Seed:        *seed,
+	}
+	var err error
+	if cfg.Mix, err = bench.ParseMix(*mix); err != nil {
+		return usagef("bench: %v", err)
+	}
+	if cfg.IDs, err = parseIDs(splitList(*ids)); err != nil {
+		return err
+	}
+
+	report, err := bench.Run(ctx, e.client, cfg)
+	if err != nil {
+		return usagef("bench: %v", err)
+	}
+	if e.printer.format == "json" {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(e.stdout, string(out))
+		return err
+	}
+	return report.WriteText(e.stdout)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service-module/pkg/userclient"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// env is what every command runs with.
+type env struct {
+	client  *userclient.Client
+	printer *printer
+	stdout  io.Writer
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{"get", "<id>", "print one user", runGet},
+	{"list", "<id>...", "print the users with the given IDs", runList},
+	{"search", "[-city C] [-phone P] [-married S] [-lname L] [-email E] [-dob D] [-country C] [-attr K=V]...", "print users matching any criteria", runSearch},
+	{"create", "-fname F -city C -phone P -height H [-married S] [-lname L] [-email E] [-dob D] [-street S] [-region R] [-postal-code P] [-country C] [-attr K=V]...", "create a user", runCreate},
+	{"update", "<id> [-fname F] [-city C] [-phone P] [-height H] [-married S] [-lname L] [-email E] [-dob D] [-street S] [-region R] [-postal-code P] [-country C] [-attr K=V]... [-if-version V]", "update the given fields of a user", runUpdate},
+	{"delete", "<id> [-if-version V]", "delete a user", runDelete},
+	{"history", "<id> [-as-of T]", "print the changes made to a user, or the user as it was at a time", runHistory},
+	{"export-data", "<id> [-out F]", "write a signed archive of everything held about a user", runExportData},
+	{"erase", "<id> [-reason R]", "permanently remove a user and its history, printing the erasure receipt", runErase},
+	{"verify-archive", "<file> [-key F]", "check the signature and contents of a user data archive", runVerifyArchive},
+	{"attributes", "", "print the registered custom attributes", runAttributes},
+	{"register-attribute", "<key> -type T [-indexed] [-values V] [-min N] [-max N] [-max-length N] [-pattern P] [-description D]", "add or redefine a custom attribute", runRegisterAttribute},
+	{"delete-attribute", "<key>", "remove a custom attribute no user has a value for", runDeleteAttribute},
+	{"unique-report", "[-fields F]", "print values held by more than one user, exiting with 65 when any are found", runUniqueReport},
+	{"watch", "[id...]", "stream changes to users until interrupted", runWatch},
+	{"import", "<file> [-format ndjson|csv] [-upsert] [-dry-run] [-report F]", "create or update users from a file, reporting rows that fail", runImport},
+	{"export", "[-format ndjson|csv] [-out F]", "write every user to a file", runExport},
+	{"bench", "[-mix M] [-d D] [-c N] [-qps Q] [-ids I] [-list-size N] [-cities C] [-seed S]", "send load and report throughput and latency", runBench},
+	{"generate", "[-n N] [-seed S] [-format ndjson|csv] [-out F] [-load]", "write synthetic users to a file or create them on the server", runGenerate},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usageError reports invalid command line arguments.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// findingsError reports a command that ran but found problems in the data it
+// checked or loaded.
+type findingsError struct {
+	msg string
+}
+
+func (e *findingsError) Error() string {
+	return e.msg
+}
+
+func findingsf(format string, args ...any) error {
+	return &findingsError{msg: fmt.Sprintf(format, args...)}
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return usagef("get takes exactly one user ID")
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := e.client.GetUser(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	return e.printer.printUsers([]*pb.User{user})
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usagef("list takes at least one user ID")
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	users, err := e.client.ListUsers(ctx, ids)
+	if err != nil {
+		return err
+	}
+	return e.printer.printUsers(users)
+}
+
+func runSearch(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("search")
+	city := fs.String("city", "", "city to match, case-insensitively")
+	phone := fs.String("phone", "", "phone number to match")
+	married := fs.String("married", "", "marital status to match, UNKNOWN matching users without one: "+maritalStatusNames())
+	lname := fs.String("lname", "", "last name to match, case-insensitively")
+	email := fs.String("email", "", "email address to match, case-insensitively")
+	dob := fs.String("dob", "", "date of birth to match, as YYYY-MM-DD")
+	country := fs.String("country", "", "ISO 3166-1 alpha-2 country code to match")
+	attrs := attrFlag{}
+	fs.Var(attrs, "attr", "indexed custom attribute to match, as key=value; repeatable")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	status, err := parseMaritalStatus(*married)
+	if err != nil {
+		return err
+	}
+	query := userclient.SearchQuery{
+		City: *city, Phone: *phone, MaritalStatus: status, UnknownMaritalStatus: *married != "" && status == pb.MaritalStatus_UNKNOWN,
+		Lname: *lname, Email: *email, DateOfBirth: *dob, Country: *country, Attributes: attrs,
+	}
+	users, err := e.client.SearchUsers(ctx, query)
+	if err != nil {
+		return err
+	}
+	return e.printer.printUsers(users)
+}
+
+func runCreate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("create")
+	user, _ := userFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	u, err := user()
+	if err != nil {
+		return err
+	}
+	created, err := e.client.CreateUser(ctx, u)
+	if err != nil {
+		return err
+	}
+	return e.printer.printUsers([]*pb.User{created})
+}
+
+func runUpdate(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("update takes the user ID before any flags")
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("update")
+	user, fields := userFlags(fs)
+	version := fs.Uint("if-version", 0, "only update if the user is still at this version")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if len(fields()) == 0 {
+		return usagef("update needs at least one field flag")
+	}
+
+	u, err := user()
+	if err != nil {
+		return err
+	}
+	u.Id = ids[0]
+	u.Version = uint32(*version)
+	updated, err := e.client.UpdateUser(ctx, u, fields()...)
+	if err != nil {
+		return err
+	}
+	return e.printer.printUsers([]*pb.User{updated})
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("delete takes the user ID before any flags")
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("delete")
+	version := fs.Uint("if-version", 0, "only delete if the user is still at this version")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if err := e.client.DeleteUserAtVersion(ctx, ids[0], uint32(*version)); err != nil {
+		return err
+	}
+	if e.printer.format == "table" {
+		fmt.Fprintf(e.stdout, "deleted user %d\n", ids[0])
+	}
+	return nil
+}
+
+// historyPageSize is the number of revisions fetched per call by history.
+const historyPageSize = 500
+
+func runHistory(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("history takes the user ID before any flags")
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("history")
+	asOf := fs.String("as-of", "", "print the user as it was at this RFC 3339 time instead of its history")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if *asOf != "" {
+		t, err := time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			return usagef("history: invalid -as-of %q, want an RFC 3339 time such as 2024-05-01T12:00:00Z", *asOf)
+		}
+		revision, err := e.client.GetUserAsOf(ctx, ids[0], t)
+		if err != nil {
+			return err
+		}
+		return e.printer.printUsers([]*pb.User{revision.User})
+	}
+
+	var revisions []*pb.UserRevision
+	for token := ""; ; {
+		page, next, err := e.client.GetUserHistory(ctx, ids[0], historyPageSize, token)
+		if err != nil {
+			return err
+		}
+		revisions = append(revisions, page...)
+		if token = next; token == "" {
+			break
+		}
+	}
+	return e.printer.printRevisions(revisions)
+}
+
+func runWatch(ctx context.Context, e *env, args []string) error {
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := e.client.WatchUsers(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := watcher.Recv()
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.printer.printEvent(event); err != nil {
+			return err
+		}
+	}
+}
+
+// userFlags registers the user field flags on fs. The returned functions
+// build the user from the parsed flags and list the fields that were set.
+func userFlags(fs *flag.FlagSet) (func() (*pb.User, error), func() []string) {
+	fname := fs.String("fname", "", "first name")
+	city := fs.String("city", "", "city")
+	phone := fs.String("phone", "", "10-digit phone number")
+	height := fs.Float64("height", 0, "height in feet")
+	married := fs.String("married", "", "marital status: "+maritalStatusNames())
+	lname := fs.String("lname", "", "last name")
+	email := fs.String("email", "", "email address")
+	dob := fs.String("dob", "", "date of birth, as YYYY-MM-DD")
+	street := fs.String("street", "", "street address")
+	region := fs.String("region", "", "state, province or region")
+	postalCode := fs.String("postal-code", "", "postal code")
+	country := fs.String("country", "", "ISO 3166-1 alpha-2 country code")
+	attrs := attrFlag{}
+	fs.Var(attrs, "attr", "custom attribute as key=value, an empty value clearing it; repeatable")
+
+	// Address and attribute flags update only their part of the user.
+	flagFields := map[string]string{
+		"fname": "fname", "city": "city", "phone": "phone", "height": "height", "married": "isMarried",
+		"lname": "lname", "email": "email", "dob": "dateOfBirth",
+		"street": "address.street", "region": "address.region", "postal-code": "address.postalCode", "country": "address.country",
+	}
+	fields := func() []string {
+		var set []string
+		fs.Visit(func(f *flag.Flag) {
+			if field, ok := flagFields[f.Name]; ok {
+				set = append(set, field)
+			}
+		})
+		for key := range attrs {
+			set = append(set, "attributes."+key)
+		}
+		sort.Strings(set[len(set)-len(attrs):])
+		return set
+	}
+	user := func() (*pb.User, error) {
+		status, err := parseMaritalStatus(*married)
+		if err != nil {
+			return nil, err
+		}
+		u := &pb.User{
+			Fname: *fname, City: *city, Phone: *phone, Height: float32(*height), IsMarried: status,
+			Lname: *lname, Email: *email, DateOfBirth: *dob,
+		}
+		for key, value := range attrs {
+			if value != "" {
+				if u.Attributes == nil {
+					u.Attributes = make(map[string]string)
+				}
+				u.Attributes[key] = value
+			}
+		}
+		if *street != "" || *region != "" || *postalCode != "" || *country != "" {
+			u.Address = &pb.Address{Street: *street, Region: *region, PostalCode: *postalCode, Country: *country}
+		}
+		return u, nil
+	}
+	return user, fields
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return usagef("%s: %v", fs.Name(), err)
+	}
+	if fs.NArg() > 0 {
+		return usagef("%s: unexpected arguments %v", fs.Name(), fs.Args())
+	}
+	return nil
+}
+
+func parseIDs(args []string) ([]uint32, error) {
+	ids := make([]uint32, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			return nil, usagef("invalid user ID %q", arg)
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
+
+func parseMaritalStatus(s string) (pb.MaritalStatus, error) {
+	if s == "" {
+		return pb.MaritalStatus_UNKNOWN, nil
+	}
+	status, found := pb.MaritalStatus_value[strings.ToUpper(s)]
+	if !found {
+		return 0, usagef("invalid marital status %q, want one of %s", s, maritalStatusNames())
+	}
+	return pb.MaritalStatus(status), nil
+}
+
+func maritalStatusNames() string {
+	values := pb.MaritalStatus(0).Descriptor().Values()
+	names := make([]string, values.Len())
+	for i := range names {
+		names[i] = string(values.Get(i).Name())
+	}
+	return strings.Join(names, ", ")
+}
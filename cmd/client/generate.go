@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"user-service-module/internal/datagen"
+	"user-service-module/internal/userfile"
+)
+
+func runGenerate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("generate")
+	n := fs.Int("n", 1000, "number of users")
+	seed := fs.Int64("seed", 1, "seed; the same seed produces the same users")
+	format := fs.String("format", userfile.FormatNDJSON, "file format: ndjson or csv")
+	out := fs.String("out", "-", `file to write, "-" for stdout`)
+	load := fs.Bool("load", false, "create the users on the server instead of writing them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *n < 0 {
+		return usagef("generate: -n must not be negative")
+	}
+	if *format != userfile.FormatNDJSON && *format != userfile.FormatCSV {
+		return usagef("generate: unknown format %q, want ndjson or csv", *format)
+	}
+
+	g := datagen.NewGenerator(*seed)
+	if *load {
+		start := time.Now()
+		created, err := datagen.Load(ctx, e.client, g, *n)
+		fmt.Fprintf(e.stdout, "created %d users in %v\n", created, time.Since(start).Round(time.Millisecond))
+		return err
+	}
+
+	if *out == "-" {
+		return datagen.Write(e.stdout, g, *n, *format)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := datagen.Write(f, g, *n, *format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,84 +2,174 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
 	"time"
-	"sync"
-	pb "user-service-module/proto/user/userpb"
+	"user-service-module/internal/logging"
+	"user-service-module/internal/tracing"
+	"user-service-module/pkg/userclient"
 
 	"google.golang.org/grpc"
 )
 
+// Exit codes. Failed RPCs exit with their gRPC status code instead, so these
+// stay clear of 0-16.
+const (
+	exitUsage      = 64
+	exitFindings   = 65
+	exitLocalError = 70
+)
+
 func main() {
-	fmt.Println("Starting gRPC client application...")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	// use grpc.Dial to connect to the running gRPC server
-	// use grpc.WithBlock() to block until the connection is established
-	conn, err := grpc.Dial("localhost:33001", grpc.WithInsecure(), grpc.WithBlock())
-	if err != nil {
-		log.Fatalf("Failed to dial: %v", err)
-		return
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs) }
+	addr := fs.String("addr", "localhost:33001", "address of the gRPC server")
+	timeout := fs.Duration("timeout", time.Second, "deadline for each request; watch is not limited")
+	useTLS := fs.Bool("tls", false, "connect over TLS")
+	tlsCA := fs.String("tls-ca", "", "PEM file with the CA used to verify the server; implies -tls")
+	tlsServerName := fs.String("tls-server-name", "", "server name to verify instead of the host in -addr; implies -tls")
+	token := fs.String("token", "", "bearer token sent with every request")
+	tenantName := fs.String("tenant", "", "tenant every request acts for; the server picks the default tenant when empty")
+	format := fs.String("o", "table", "output format: "+strings.Join(formats, ", "))
+	traceDest := fs.String("trace", "", `export trace spans to "stdout" or a file path; tracing is disabled when empty`)
+	logFormat := fs.String("log-format", "text", `log output format, "text" or "json"`)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
 	}
 
-	defer conn.Close()
+	if fs.NArg() == 0 {
+		usage(fs)
+		return exitUsage
+	}
+	cmd, found := findCommand(fs.Arg(0))
+	if !found && fs.Arg(0) != shellCommand.name {
+		fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(0))
+		usage(fs)
+		return exitUsage
+	}
 
-	// use the NewGreetingServiceClient method of the generated in the .pb.go file
-	// to create a new GreetingServiceClient object
-	// this object can be used to call methods implemented in the grpc server
-	wg := sync.WaitGroup{}
-	client := pb.NewUserServiceClient(conn)
+	p, err := newPrinter(stdout, *format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
 
-	// Call GetUser
-	wg.Add(1)
-	go getUser(client, 1, &wg)
+	logger, err := logging.New(stderr, *logFormat, slog.LevelInfo)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	slog.SetDefault(logger)
+
+	if *traceDest != "" {
+		shutdown, err := tracing.Setup("user-service-client", *traceDest)
+		if err != nil {
+			slog.Error("Failed to set up tracing", "error", err)
+			return exitLocalError
+		}
+		defer shutdown(context.Background())
+	}
 
-	// Call ListUsers
-	wg.Add(1)
-	go listUsers(client, []uint32{1, 2, 3}, &wg)
+	opts := []userclient.Option{
+		userclient.WithToken(*token),
+		userclient.WithTenant(*tenantName),
+		userclient.WithTimeout(*timeout),
+		userclient.WithDialOptions(grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor())),
+	}
+	if *useTLS || *tlsCA != "" || *tlsServerName != "" {
+		cfg, err := tlsConfig(*tlsCA, *tlsServerName)
+		if err != nil {
+			slog.Error("Invalid TLS configuration", "error", err)
+			return exitLocalError
+		}
+		opts = append(opts, userclient.WithTLS(cfg))
+	}
 
-	// Call SearchUsers
-	wg.Add(1)
-	go searchUsers(client, "LA", "", pb.MaritalStatus_MARRIED, &wg)
+	client, err := userclient.New(*addr, opts...)
+	if err != nil {
+		slog.Error("Failed to create client", "error", err)
+		return exitLocalError
+	}
+	defer client.Close()
 
-	wg.Wait()
+	e := &env{client: client, printer: p, stdout: stdout}
+	if !found {
+		err = runShell(context.Background(), e, stdin, stderr, fs.Args()[1:])
+	} else {
+		err = runCommand(context.Background(), e, cmd, fs.Args()[1:])
+	}
+	return exitCode(err, stderr, fs)
 }
 
-func getUser(client pb.UserServiceClient, id uint32, wg *sync.WaitGroup) {
-	defer wg.Done()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	req := &pb.GetUserRequest{Id: id}
-	res, err := client.GetUser(ctx, req)
-	if err != nil {
-		log.Fatalf("could not get user: %v", err)
+// exitCode reports err on stderr and picks the exit code for it.
+func exitCode(err error, stderr io.Writer, fs *flag.FlagSet) int {
+	var usageErr *usageError
+	var findingsErr *findingsError
+	var rpcErr *userclient.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(stderr, err)
+		usage(fs)
+		return exitUsage
+	case errors.As(err, &findingsErr):
+		fmt.Fprintln(stderr, err)
+		return exitFindings
+	case errors.As(err, &rpcErr):
+		slog.Error("Request failed", "code", rpcErr.Code, "error", rpcErr.Message, "request_id", rpcErr.RequestID)
+		return int(rpcErr.Code)
+	default:
+		slog.Error("Command failed", "error", err)
+		return exitLocalError
 	}
-	log.Printf("GetUser Response: %v", res)
 }
 
-func listUsers(client pb.UserServiceClient, ids []uint32, wg *sync.WaitGroup) {
-	defer wg.Done()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+func tlsConfig(caFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
 
-	req := &pb.ListUsersRequest{Ids: ids}
-	res, err := client.ListUsers(ctx, req)
+	pem, err := os.ReadFile(caFile)
 	if err != nil {
-		log.Fatalf("could not list users: %v", err)
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
 	}
-	log.Printf("ListUsers Response: %v", res)
+	cfg.RootCAs = pool
+	return cfg, nil
 }
 
-func searchUsers(client pb.UserServiceClient, city, phone string, isMarried pb.MaritalStatus, wg *sync.WaitGroup) {
-	defer wg.Done()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	req := &pb.SearchUsersRequest{City: city, Phone: phone, IsMarried: isMarried}
-	res, err := client.SearchUsers(ctx, req)
-	if err != nil {
-		log.Fatalf("could not search users: %v", err)
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: client [flags] <command> [args]\n\nCommands:\n")
+	for _, c := range append(append([]command{}, commands...), shellCommand) {
+		fmt.Fprintf(w, "  %-7s %s\n          %s\n", c.name, c.args, c.summary)
 	}
-	log.Printf("SearchUsers Response: %v", res)
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nExit codes:\n"+
+		"  0       success\n"+
+		"  1-16    the gRPC status code of a failed request\n"+
+		"  %-7d invalid usage\n"+
+		"  %-7d the command found problems, such as failed import rows\n"+
+		"  %-7d other local errors\n", exitUsage, exitFindings, exitLocalError)
 }
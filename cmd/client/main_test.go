@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	serviceerrors "user-service-module/internal/errors"
+	"user-service-module/internal/gdpr"
+	"user-service-module/internal/redact"
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// now is the time the test server records on users and their history.
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func startServer(t *testing.T, opts ...server.Option) string {
+	t.Helper()
+	return serve(t, grpc.NewServer(grpc.UnaryInterceptor(serviceerrors.UnaryServerInterceptor())), opts...)
+}
+
+// startRedactingServer starts a server masking fields as one without a policy
+// does, where no caller may read them.
+func startRedactingServer(t *testing.T, fields []string, opts ...server.Option) string {
+	t.Helper()
+	r, err := redact.NewRedactor(fields, func(context.Context) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, grpc.NewServer(
+		grpc.ChainUnaryInterceptor(r.UnaryServerInterceptor(), serviceerrors.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(r.StreamServerInterceptor()),
+	), opts...)
+}
+
+func serve(t *testing.T, s *grpc.Server, opts ...server.Option) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]server.Option{server.WithClock(func() time.Time { return now })}, opts...)
+	pb.RegisterUserServiceServer(s, server.NewUserServer(opts...))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	addr := startServer(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{
+			name:     "get as table",
+			args:     []string{"get", "1"},
+			wantCode: 0,
+			wantOut:  "ID  FNAME  CITY  PHONE       HEIGHT  ISMARRIED  VERSION\n1   Steve  LA    9827329211  5.8     MARRIED    1\n",
+		},
+		{
+			name:     "list as csv",
+			args:     []string{"-o", "csv", "list", "1", "2"},
+			wantCode: 0,
+			wantOut:  "id,fname,city,phone,height,isMarried,version\n1,Steve,LA,9827329211,5.8,MARRIED,1\n2,Bob,NY,9876543210,6.1,SINGLE,1\n",
+		},
+		{
+			name:     "search as yaml",
+			args:     []string{"-o", "yaml", "search", "-city", "ny"},
+			wantCode: 0,
+			wantOut: "- id: 2\n  fname: Bob\n  city: NY\n  phone: \"9876543210\"\n  height: 6.1\n  isMarried: SINGLE\n  version: 1\n" +
+				"  lname: \"\"\n  email: \"\"\n  dateOfBirth: \"\"\n  address: null\n  createTime: \"2024-05-01T12:00:00Z\"\n  updateTime: \"2024-05-01T12:00:00Z\"\n  attributes: {}\n",
+		},
+		{
+			name:     "update as json",
+			args:     []string{"-o", "json", "update", "3", "-city", "SF"},
+			wantCode: 0,
+			wantOut: "[\n  {\n    \"id\": 3,\n    \"fname\": \"Alice\",\n    \"city\": \"SF\",\n    \"phone\": \"9876545876\",\n    \"height\": 5.5,\n    \"isMarried\": \"MARRIED\",\n    \"version\": 2,\n" +
+				"    \"lname\": \"\",\n    \"email\": \"\",\n    \"dateOfBirth\": \"\",\n    \"address\": null,\n" +
+				"    \"createTime\": \"2024-05-01T12:00:00Z\",\n    \"updateTime\": \"2024-05-01T12:00:00Z\",\n    \"attributes\": {}\n  }\n]\n",
+		},
+		{
+			name:     "create",
+			args:     []string{"-o", "csv", "create", "-fname", "Eve", "-city", "SF", "-phone", "1234567890", "-height", "5.2", "-married", "single"},
+			wantCode: 0,
+			wantOut:  "id,fname,city,phone,height,isMarried,version\n4,Eve,SF,1234567890,5.2,SINGLE,1\n",
+		},
+		{
+			name:     "create with profile fields",
+			args:     []string{"-o", "csv", "create", "-fname", "Eve", "-city", "SF", "-phone", "1234567891", "-height", "5.2", "-lname", "Doe", "-email", "eve@example.com", "-dob", "1990-02-28", "-country", "US"},
+			wantCode: 0,
+			wantOut:  "id,fname,city,phone,height,isMarried,version\n5,Eve,SF,1234567891,5.2,UNKNOWN,1\n",
+		},
+		{
+			name:     "search by profile fields",
+			args:     []string{"-o", "csv", "search", "-lname", "doe", "-country", "US"},
+			wantCode: 0,
+			wantOut:  "id,fname,city,phone,height,isMarried,version\n5,Eve,SF,1234567891,5.2,UNKNOWN,1\n",
+		},
+		{
+			name:     "search by unknown marital status",
+			args:     []string{"-o", "csv", "search", "-married", "unknown"},
+			wantCode: 0,
+			wantOut:  "id,fname,city,phone,height,isMarried,version\n5,Eve,SF,1234567891,5.2,UNKNOWN,1\n",
+		},
+		{
+			name:     "invalid profile fields exit with status code",
+			args:     []string{"update", "5", "-country", "usa"},
+			wantCode: int(codes.InvalidArgument),
+		},
+		{
+			name:     "stale update exits with status code",
+			args:     []string{"update", "3", "-city", "NY", "-if-version", "1"},
+			wantCode: int(codes.FailedPrecondition),
+		},
+		{
+			name:     "stale delete exits with status code",
+			args:     []string{"delete", "2", "-if-version", "3"},
+			wantCode: int(codes.FailedPrecondition),
+		},
+		{
+			name:     "delete",
+			args:     []string{"delete", "2", "-if-version", "1"},
+			wantCode: 0,
+			wantOut:  "deleted user 2\n",
+		},
+		{
+			name:     "not found exits with status code",
+			args:     []string{"get", "99"},
+			wantCode: int(codes.NotFound),
+		},
+		{
+			name:     "invalid fields exit with status code",
+			args:     []string{"update", "1", "-phone", "123"},
+			wantCode: int(codes.InvalidArgument),
+		},
+		{
+			name:     "update without fields",
+			args:     []string{"update", "1"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "invalid ID",
+			args:     []string{"get", "abc"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"rename", "1"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "unknown output format",
+			args:     []string{"-o", "xml", "get", "1"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "invalid marital status",
+			args:     []string{"search", "-married", "maybe"},
+			wantCode: exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(append([]string{"-addr", addr}, tt.args...), strings.NewReader(""), &stdout, &stderr)
+
+			assert.Equal(t, tt.wantCode, code, stderr.String())
+			assert.Equal(t, tt.wantOut, stdout.String())
+		})
+	}
+}
+
+func TestUsageExitCodes(t *testing.T) {
+	var stderr bytes.Buffer
+	code := run([]string{"-h"}, strings.NewReader(""), io.Discard, &stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr.String(), "  64      invalid usage\n")
+	assert.Contains(t, stderr.String(), "  65      the command found problems")
+	assert.Contains(t, stderr.String(), "  70      other local errors\n")
+}
+
+func TestHistory(t *testing.T) {
+	addr := startServer(t)
+	before := now.Add(-time.Hour).Format(time.RFC3339)
+	run([]string{"-addr", addr, "update", "2", "-city", "Boston"}, strings.NewReader(""), io.Discard, io.Discard)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "-o", "csv", "history", "2"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, []string{"time", "type", "actor", "version", "changes"}, rows[0])
+		assert.Equal(t, []string{"CREATED", "system", "1"}, rows[1][1:4])
+		assert.Equal(t, []string{"UPDATED", "anonymous", "2", "city: NY -> Boston"}, rows[2][1:])
+	}
+
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "-o", "csv", "history", "2", "-as-of", now.Format(time.RFC3339Nano)}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "id,fname,city,phone,height,isMarried,version\n2,Bob,Boston,9876543210,6.1,SINGLE,2\n", stdout.String())
+
+	code = run([]string{"-addr", addr, "history", "2", "-as-of", before}, strings.NewReader(""), io.Discard, io.Discard)
+	assert.Equal(t, int(codes.NotFound), code)
+
+	code = run([]string{"-addr", addr, "history", "2", "-as-of", "yesterday"}, strings.NewReader(""), io.Discard, io.Discard)
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestUserData(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userServer := server.NewUserServer()
+	s := grpc.NewServer(grpc.UnaryInterceptor(serviceerrors.UnaryServerInterceptor()))
+	pb.RegisterUserServiceServer(s, userServer)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	addr := lis.Addr().String()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "bob.zip")
+	pubFile := filepath.Join(dir, "pub.pem")
+	pub, err := gdpr.EncodePublicKey(userServer.ArchivePublicKey())
+	assert.NoError(t, err)
+	os.WriteFile(pubFile, pub, 0o600)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "export-data", "2", "-out", archive}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "exported the data of user 2 to "+archive+"\n", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "verify-archive", archive, "-key", pubFile}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "archive of user 2 of tenant default created at ")
+
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "-o", "csv", "erase", "2", "-reason", "ticket 42"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, []string{"id", "time", "actor", "reason", "revisions_erased", "cached_responses_erased"}, rows[0])
+		assert.Equal(t, []string{"2", "anonymous", "ticket 42", "1", "0"}, append(rows[1][:1], rows[1][2:]...))
+	}
+
+	code = run([]string{"-addr", addr, "export-data", "2", "-out", archive}, strings.NewReader(""), io.Discard, io.Discard)
+	assert.Equal(t, int(codes.NotFound), code)
+
+	os.WriteFile(archive, []byte("not a zip"), 0o600)
+	code = run([]string{"-addr", addr, "verify-archive", archive}, strings.NewReader(""), io.Discard, io.Discard)
+	assert.Equal(t, exitLocalError, code)
+}
+
+func TestAttributes(t *testing.T) {
+	addr := startServer(t)
+	exec := func(args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"-addr", addr}, args...), strings.NewReader(""), &stdout, &stderr)
+		return code, stdout.String() + stderr.String()
+	}
+
+	code, out := exec("register-attribute", "team", "-type", "enum", "-values", "core,growth", "-indexed", "-description", "owning team")
+	assert.Equal(t, 0, code, out)
+	code, out = exec("register-attribute", "level", "-type", "int", "-min", "1", "-max", "9")
+	assert.Equal(t, 0, code, out)
+	code, out = exec("attributes")
+	assert.Equal(t, 0, code, out)
+	assert.Equal(t, "KEY    TYPE  INDEXED  RULES               DESCRIPTION\n"+
+		"level  INT   false    min=1, max=9        -\n"+
+		"team   ENUM  true     values=core|growth  owning team\n", out)
+
+	code, out = exec("-o", "csv", "update", "2", "-attr", "team=core", "-attr", "level=3")
+	assert.Equal(t, 0, code, out)
+	code, out = exec("-o", "csv", "search", "-attr", "team=core")
+	assert.Equal(t, 0, code, out)
+	assert.Equal(t, "id,fname,city,phone,height,isMarried,version\n2,Bob,NY,9876543210,6.1,SINGLE,2\n", out)
+
+	code, _ = exec("search", "-attr", "level=3")
+	assert.Equal(t, int(codes.InvalidArgument), code, "unindexed attributes cannot be searched")
+	code, _ = exec("update", "2", "-attr", "level=10")
+	assert.Equal(t, int(codes.InvalidArgument), code)
+	code, _ = exec("delete-attribute", "team")
+	assert.Equal(t, int(codes.FailedPrecondition), code)
+	code, _ = exec("register-attribute", "vip", "-type", "yes/no")
+	assert.Equal(t, exitUsage, code)
+
+	code, out = exec("update", "2", "-attr", "team=")
+	assert.Equal(t, 0, code, out)
+	code, out = exec("delete-attribute", "team")
+	assert.Equal(t, 0, code, out)
+	assert.Equal(t, "deleted attribute team\n", out)
+}
+
+func TestUniqueReport(t *testing.T) {
+	addr := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "unique-report"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "FIELD  VALUE  IDS\n", stdout.String())
+
+	run([]string{"-addr", addr, "create", "-fname", "Carol", "-city", "SF", "-phone", "9876543210", "-height", "5.4"}, strings.NewReader(""), io.Discard, io.Discard)
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "-o", "csv", "unique-report", "-fields", "phone"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, exitFindings, code, "violations should fail the report")
+	assert.Equal(t, "field,value,ids\nphone,9876543210,2 4\n", stdout.String())
+
+	code = run([]string{"-addr", addr, "unique-report", "-fields", "city"}, strings.NewReader(""), io.Discard, io.Discard)
+	assert.Equal(t, int(codes.InvalidArgument), code)
+}
+
+func TestGenerate(t *testing.T) {
+	addr := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "generate", "-n", "2", "-format", "csv"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Len(t, strings.Split(strings.TrimSpace(stdout.String()), "\n"), 3)
+
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "generate", "-n", "20", "-load"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "created 20 users")
+
+	stdout.Reset()
+	code = run([]string{"-addr", addr, "-o", "csv", "get", "23"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+}
+
+func TestImportExport(t *testing.T) {
+	addr := startServer(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "users.csv")
+	err := os.WriteFile(input, []byte("id,fname,city,phone,height,isMarried\n"+
+		"1,Steve,Boston,9827329211,5.8,MARRIED\n"+
+		",Carol,SF,9123456789,5.4,SINGLE\n"+
+		",Eve,SF,123,5,SINGLE\n"+
+		",Dave,SF,9000000001,tall,SINGLE\n"), 0o644)
+	assert.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "import", input, "-dry-run"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, exitFindings, code)
+	assert.Equal(t, "1 would be created, 0 would be updated, 3 failed\n"+
+		"LINE  ERROR\n"+
+		"2     error: user(s) already exist: 1\n"+
+		"4     error: invalid field(s): phone\n"+
+		"5     invalid height \"tall\"\n", stdout.String())
+
+	stdout.Reset()
+	report := filepath.Join(dir, "report.csv")
+	code = run([]string{"-addr", addr, "-o", "json", "import", input, "-upsert", "-report", report}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stdout.String(), `"created": 1,`)
+	assert.Contains(t, stdout.String(), `"updated": 1,`)
+	written, err := os.ReadFile(report)
+	assert.NoError(t, err)
+	assert.Equal(t, "line,error\n4,error: invalid field(s): phone\n5,\"invalid height \"\"tall\"\"\"\n", string(written))
+
+	stdout.Reset()
+	output := filepath.Join(dir, "out.csv")
+	code = run([]string{"-addr", addr, "export", "-out", output}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "exported 4 users to "+output+"\n", stdout.String())
+	exported, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	// Server times and empty attributes
+	tail := "2024-05-01T12:00:00Z,2024-05-01T12:00:00Z,"
+	assert.Equal(t, "id,fname,city,phone,height,isMarried,version,lname,email,dateOfBirth,street,region,postalCode,country,createTime,updateTime,attributes\n"+
+		"1,Steve,Boston,9827329211,5.8,MARRIED,2,,,,,,,,"+tail+"\n"+
+		"2,Bob,NY,9876543210,6.1,SINGLE,1,,,,,,,,"+tail+"\n"+
+		"3,Alice,LA,9876545876,5.5,MARRIED,1,,,,,,,,"+tail+"\n"+
+		"4,Carol,SF,9123456789,5.4,SINGLE,1,,,,,,,,"+tail+"\n", string(exported))
+}
+
+func TestExportMigration(t *testing.T) {
+	dir := t.TempDir()
+	target := startServer(t, server.WithoutSampleUsers())
+
+	var stdout, stderr bytes.Buffer
+	masked := filepath.Join(dir, "masked.csv")
+	code := run([]string{"-addr", startRedactingServer(t, []string{"phone"}), "export", "-out", masked}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	stdout.Reset()
+	code = run([]string{"-addr", target, "import", masked}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, exitFindings, code, "masked phones should fail validation")
+	assert.Contains(t, stdout.String(), "0 created, 0 updated, 3 failed\n")
+
+	full := filepath.Join(dir, "full.csv")
+	code = run([]string{"-addr", startRedactingServer(t, nil), "export", "-out", full}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	stdout.Reset()
+	code = run([]string{"-addr", target, "import", full}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 0, code, stdout.String())
+	assert.Equal(t, "3 created, 0 updated, 0 failed\n", stdout.String())
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by the -o flag.
+var formats = []string{"table", "json", "yaml", "csv"}
+
+type column struct {
+	name  string
+	value func(u *pb.User) string
+}
+
+var columns = []column{
+	{"id", func(u *pb.User) string { return strconv.FormatUint(uint64(u.Id), 10) }},
+	{"fname", func(u *pb.User) string { return u.Fname }},
+	{"city", func(u *pb.User) string { return u.City }},
+	{"phone", func(u *pb.User) string { return u.Phone }},
+	{"height", func(u *pb.User) string { return strconv.FormatFloat(float64(u.Height), 'f', -1, 32) }},
+	{"isMarried", func(u *pb.User) string { return u.IsMarried.String() }},
+	{"version", func(u *pb.User) string { return strconv.FormatUint(uint64(u.Version), 10) }},
+}
+
+var jsonOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// printer writes users in one output format.
+type printer struct {
+	w      io.Writer
+	format string
+
+	// csvHeader records whether the CSV header was already written, so
+	// streamed events share a single header.
+	csvHeader bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	for _, f := range formats {
+		if f == format {
+			return &printer{w: w, format: format}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output format %q, want one of %s", format, strings.Join(formats, ", "))
+}
+
+// printUsers writes a list of users.
+func (p *printer) printUsers(users []*pb.User) error {
+	switch p.format {
+	case "json":
+		return p.printJSON(toMessages(users), true)
+	case "yaml":
+		return p.printYAML(toMessages(users), true)
+	case "csv":
+		return p.printCSV(nil, users)
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columnNames(), "\t")))
+		for _, u := range users {
+			fmt.Fprintln(tw, strings.Join(rowOf(u), "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// printEvent writes a single change event as it arrives.
+func (p *printer) printEvent(event *pb.UserEvent) error {
+	switch p.format {
+	case "json":
+		return p.printJSON([]proto.Message{event}, false)
+	case "yaml":
+		return p.printYAML([]proto.Message{event}, false)
+	case "csv":
+		return p.printCSV([]string{event.Type.String()}, []*pb.User{event.User})
+	default:
+		_, err := fmt.Fprintf(p.w, "%s\t%s\n", event.Type, strings.Join(rowOf(event.User), "\t"))
+		return err
+	}
+}
+
+// revisionColumns are the columns of a revision in table and CSV output.
+var revisionColumns = []string{"time", "type", "actor", "version", "changes"}
+
+// printRevisions writes the revisions of a user.
+func (p *printer) printRevisions(revisions []*pb.UserRevision) error {
+	msgs := make([]proto.Message, len(revisions))
+	rows := make([][]string, len(revisions))
+	for i, r := range revisions {
+		msgs[i] = r
+		rows[i] = []string{
+			r.Time.AsTime().Format(time.RFC3339Nano),
+			r.Type.String(),
+			r.Actor,
+			strconv.FormatUint(uint64(r.User.GetVersion()), 10),
+			formatChanges(r.Changes),
+		}
+	}
+
+	switch p.format {
+	case "json":
+		return p.printJSON(msgs, true)
+	case "yaml":
+		return p.printYAML(msgs, true)
+	case "csv":
+		w := csv.NewWriter(p.w)
+		w.Write(revisionColumns)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(revisionColumns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// receiptColumns are the columns of an erasure receipt in table and CSV
+// output.
+var receiptColumns = []string{"id", "time", "actor", "reason", "revisions_erased", "cached_responses_erased"}
+
+// printReceipt writes the receipt of an erasure.
+func (p *printer) printReceipt(receipt *pb.ErasureReceipt) error {
+	row := []string{
+		strconv.FormatUint(uint64(receipt.Id), 10),
+		receipt.Time.AsTime().Format(time.RFC3339Nano),
+		receipt.Actor,
+		receipt.Reason,
+		strconv.FormatUint(uint64(receipt.RevisionsErased), 10),
+		strconv.FormatUint(uint64(receipt.CachedResponsesErased), 10),
+	}
+
+	switch p.format {
+	case "json":
+		return p.printJSON([]proto.Message{receipt}, false)
+	case "yaml":
+		return p.printYAML([]proto.Message{receipt}, false)
+	case "csv":
+		w := csv.NewWriter(p.w)
+		w.Write(receiptColumns)
+		w.Write(row)
+		w.Flush()
+		return w.Error()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(receiptColumns, "\t")))
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+		return tw.Flush()
+	}
+}
+
+// attributeColumns are the columns of an attribute definition in table and
+// CSV output.
+var attributeColumns = []string{"key", "type", "indexed", "rules", "description"}
+
+// printAttributes writes attribute definitions. Empty table cells show as
+// "-".
+func (p *printer) printAttributes(defs []*pb.AttributeDefinition) error {
+	msgs := make([]proto.Message, len(defs))
+	rows := make([][]string, len(defs))
+	for i, d := range defs {
+		msgs[i] = d
+		rows[i] = []string{d.Key, d.Type.String(), strconv.FormatBool(d.Indexed), formatRules(d), d.Description}
+	}
+
+	switch p.format {
+	case "json":
+		return p.printJSON(msgs, true)
+	case "yaml":
+		return p.printYAML(msgs, true)
+	case "csv":
+		w := csv.NewWriter(p.w)
+		w.Write(attributeColumns)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(attributeColumns, "\t")))
+		for _, row := range rows {
+			for i, cell := range row {
+				if cell == "" {
+					row[i] = "-"
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// formatRules formats the value rules of an attribute as "rule=value"
+// separated by commas, joining enum values with "|".
+func formatRules(d *pb.AttributeDefinition) string {
+	var rules []string
+	if d.MaxLength > 0 {
+		rules = append(rules, "maxLength="+strconv.FormatUint(uint64(d.MaxLength), 10))
+	}
+	if d.Pattern != "" {
+		rules = append(rules, "pattern="+d.Pattern)
+	}
+	if d.Min != nil {
+		rules = append(rules, "min="+strconv.FormatInt(*d.Min, 10))
+	}
+	if d.Max != nil {
+		rules = append(rules, "max="+strconv.FormatInt(*d.Max, 10))
+	}
+	if len(d.Values) > 0 {
+		rules = append(rules, "values="+strings.Join(d.Values, "|"))
+	}
+	return strings.Join(rules, ", ")
+}
+
+// violationColumns are the columns of a unique violation in table and CSV
+// output.
+var violationColumns = []string{"field", "value", "ids"}
+
+// printViolations writes the values held by more than one user.
+func (p *printer) printViolations(violations []*pb.UniqueViolation) error {
+	msgs := make([]proto.Message, len(violations))
+	rows := make([][]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = v
+		ids := make([]string, len(v.Ids))
+		for j, id := range v.Ids {
+			ids[j] = strconv.FormatUint(uint64(id), 10)
+		}
+		rows[i] = []string{v.Field, v.Value, strings.Join(ids, " ")}
+	}
+
+	switch p.format {
+	case "json":
+		return p.printJSON(msgs, true)
+	case "yaml":
+		return p.printYAML(msgs, true)
+	case "csv":
+		w := csv.NewWriter(p.w)
+		w.Write(violationColumns)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(violationColumns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// formatChanges formats field changes as "field: old -> new" separated by
+// commas, showing unset values as "".
+func formatChanges(changes []*pb.FieldChange) string {
+	parts := make([]string, len(changes))
+	for i, c := range changes {
+		old, updated := c.Old, c.New
+		if old == "" {
+			old = `""`
+		}
+		if updated == "" {
+			updated = `""`
+		}
+		parts[i] = c.Field + ": " + old + " -> " + updated
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printJSON writes messages as an indented JSON array, or as one compact
+// object per line when asList is false.
+func (p *printer) printJSON(msgs []proto.Message, asList bool) error {
+	raw, err := marshalJSON(msgs)
+	if err != nil {
+		return err
+	}
+	if !asList {
+		for _, r := range raw {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, r); err != nil {
+				return err
+			}
+			fmt.Fprintln(p.w, buf.String())
+		}
+		return nil
+	}
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.w, string(out))
+	return err
+}
+
+// printYAML writes messages as a YAML sequence, or as one document per
+// message when asList is false. Field order follows the proto definition.
+func (p *printer) printYAML(msgs []proto.Message, asList bool) error {
+	raw, err := marshalJSON(msgs)
+	if err != nil {
+		return err
+	}
+	list, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML; decoding into a node keeps key order and types
+	var doc yaml.Node
+	if err := yaml.Unmarshal(list, &doc); err != nil {
+		return err
+	}
+	resetStyle(&doc)
+
+	enc := yaml.NewEncoder(p.w)
+	enc.SetIndent(2)
+	seq := doc.Content[0]
+	if asList {
+		if err := enc.Encode(seq); err != nil {
+			return err
+		}
+	} else {
+		for _, item := range seq.Content {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Close()
+}
+
+func (p *printer) printCSV(prefix []string, users []*pb.User) error {
+	w := csv.NewWriter(p.w)
+	if !p.csvHeader {
+		header := columnNames()
+		if prefix != nil {
+			header = append([]string{"event"}, header...)
+		}
+		w.Write(header)
+		p.csvHeader = true
+	}
+	for _, u := range users {
+		w.Write(append(append([]string{}, prefix...), rowOf(u)...))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func marshalJSON(msgs []proto.Message) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(msgs))
+	for _, m := range msgs {
+		b, err := jsonOptions.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, b)
+	}
+	return raw, nil
+}
+
+// resetStyle switches nodes parsed from JSON to block style. Strings that
+// look like numbers, such as phone numbers, stay quoted.
+func resetStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetStyle(c)
+	}
+}
+
+func toMessages(users []*pb.User) []proto.Message {
+	msgs := make([]proto.Message, len(users))
+	for i, u := range users {
+		msgs[i] = u
+	}
+	return msgs
+}
+
+func columnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+func rowOf(u *pb.User) []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.value(u)
+	}
+	return row
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"user-service-module/internal/gdpr"
+)
+
+func runExportData(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("export-data takes the user ID before any flags")
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("export-data")
+	out := fs.String("out", fmt.Sprintf("user-%d.zip", ids[0]), "archive file to write")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	archive, err := e.client.ExportUserData(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, archive, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "exported the data of user %d to %s\n", ids[0], *out)
+	return nil
+}
+
+func runErase(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("erase takes the user ID before any flags")
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("erase")
+	reason := fs.String("reason", "", "why the user is erased, such as a ticket reference; kept in the receipt")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	receipt, err := e.client.EraseUser(ctx, ids[0], *reason)
+	if err != nil {
+		return err
+	}
+	return e.printer.printReceipt(receipt)
+}
+
+func runVerifyArchive(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("verify-archive takes the archive file before any flags")
+	}
+	fs := newFlagSet("verify-archive")
+	keyFile := fs.String("key", "", "PEM file with the server's public key; without it only the integrity of the archive is checked")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	var pub ed25519.PublicKey
+	if *keyFile != "" {
+		var err error
+		if pub, err = gdpr.LoadPublicKey(*keyFile); err != nil {
+			return err
+		}
+	}
+	archive, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	manifest, err := gdpr.Verify(archive, pub)
+	if err != nil {
+		return err
+	}
+
+	signer := "the key in its manifest"
+	if pub != nil {
+		signer = *keyFile
+	}
+	user := fmt.Sprintf("user %d", manifest.UserID)
+	if manifest.Tenant != "" {
+		user += " of tenant " + manifest.Tenant
+	}
+	fmt.Fprintf(e.stdout, "%s: archive of %s created at %s, %d files, signed by %s\n",
+		args[0], user, manifest.CreatedAt.Format(time.RFC3339), len(manifest.Files), signer)
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+const shellPrompt = "user> "
+
+// shellCommand starts the interactive shell. It is kept out of commands
+// because the shell itself dispatches to them.
+var shellCommand = command{name: "shell", summary: "start a prompt that reuses one connection for many commands"}
+
+// shellBuiltins are the commands handled by the shell itself.
+var shellBuiltins = []command{
+	{name: "help", summary: "list commands"},
+	{name: "output", args: "[format]", summary: "show or change the output format: " + strings.Join(formats, ", ")},
+	{name: "history", summary: "without arguments, list the commands entered so far"},
+	{name: "exit", summary: "leave the shell; Ctrl-D also works"},
+}
+
+// lineReader reads one line of shell input at a time.
+type lineReader interface {
+	readLine() (string, error)
+}
+
+// shell runs commands read line by line against one client. Errors are
+// reported and the shell keeps going.
+type shell struct {
+	env     *env
+	in      lineReader
+	stderr  io.Writer
+	history []string
+}
+
+// runShell reads commands from stdin until EOF or exit. When stdin is a
+// terminal it offers line editing, history and tab completion.
+func runShell(ctx context.Context, e *env, stdin io.Reader, stderr io.Writer, args []string) error {
+	if len(args) > 0 {
+		return usagef("shell takes no arguments")
+	}
+
+	sh := &shell{env: e, stderr: stderr}
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{f, e.stdout}, shellPrompt)
+		t.AutoCompleteCallback = complete
+		sh.in = &terminalReader{fd: int(f.Fd()), term: t}
+		fmt.Fprintln(e.stdout, `Type "help" for commands, Tab to complete, Ctrl-D to exit.`)
+	} else {
+		sh.in = &scannerReader{scanner: bufio.NewScanner(stdin)}
+	}
+	return sh.run(ctx)
+}
+
+func (sh *shell) run(ctx context.Context) error {
+	for {
+		line, err := sh.in.readLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sh.history = append(sh.history, line)
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintf(sh.stderr, "error: %v\n", err)
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+		if err := sh.exec(ctx, args); err != nil {
+			sh.report(args[0], err)
+		}
+	}
+}
+
+func (sh *shell) exec(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "help":
+		sh.help()
+		return nil
+	case "history":
+		if len(args) > 1 {
+			// history <id> is the command printing a user's changes
+			break
+		}
+		for i, line := range sh.history {
+			fmt.Fprintf(sh.env.stdout, "%4d  %s\n", i+1, line)
+		}
+		return nil
+	case "output":
+		if len(args) == 1 {
+			fmt.Fprintln(sh.env.stdout, sh.env.printer.format)
+			return nil
+		}
+		p, err := newPrinter(sh.env.stdout, args[1])
+		if err != nil {
+			return usagef("%v", err)
+		}
+		sh.env.printer = p
+		return nil
+	}
+
+	cmd, found := findCommand(args[0])
+	if !found {
+		return usagef("unknown command %q", args[0])
+	}
+	// A fresh printer per command so every CSV result gets its own header
+	sh.env.printer = &printer{w: sh.env.printer.w, format: sh.env.printer.format}
+	return runCommand(ctx, sh.env, cmd, args[1:])
+}
+
+func (sh *shell) report(name string, err error) {
+	var usageErr *usageError
+	fmt.Fprintf(sh.stderr, "error: %v\n", err)
+	if !errors.As(err, &usageErr) {
+		return
+	}
+	if cmd, found := findCommand(name); found {
+		fmt.Fprintf(sh.stderr, "usage: %s %s\n", cmd.name, cmd.args)
+	}
+}
+
+func (sh *shell) help() {
+	for _, c := range append(append([]command{}, commands...), shellBuiltins...) {
+		fmt.Fprintf(sh.env.stdout, "  %-7s %s\n          %s\n", c.name, c.args, c.summary)
+	}
+}
+
+// runCommand runs cmd until it finishes or the user interrupts it.
+func runCommand(ctx context.Context, e *env, cmd command, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return cmd.run(ctx, e, args)
+}
+
+// terminalReader puts the terminal in raw mode only while a line is edited,
+// so commands such as watch can still be stopped with Ctrl-C.
+type terminalReader struct {
+	fd   int
+	term *term.Terminal
+}
+
+func (r *terminalReader) readLine() (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(r.fd, state)
+	return r.term.ReadLine()
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *scannerReader) readLine() (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// complete expands the word before the cursor when Tab is pressed: command
+// names first, then the flags of that command and marital status values.
+func complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t") + 1
+	words := strings.Fields(head[:start])
+	word := head[start:]
+
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		for _, c := range append(append([]command{}, commands...), shellBuiltins...) {
+			candidates = append(candidates, c.name)
+		}
+	case words[len(words)-1] == "-married":
+		candidates = strings.Split(maritalStatusNames(), ", ")
+	case words[0] == "output" && len(words) == 1:
+		candidates = formats
+	default:
+		if cmd, found := findCommand(words[0]); found {
+			candidates = cmd.flagNames()
+		}
+	}
+
+	completion, unique := commonPrefix(word, candidates)
+	if completion == word && !unique {
+		return "", 0, false
+	}
+	if unique && !strings.HasPrefix(line[pos:], " ") {
+		completion += " "
+	}
+	return head[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// commonPrefix returns the longest completion of word shared by all
+// candidates, and whether exactly one candidate matched.
+func commonPrefix(word string, candidates []string) (string, bool) {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(word)) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return word, false
+	}
+	sort.Strings(matches)
+
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) < len(word) {
+		return word, false
+	}
+	return prefix, len(matches) == 1
+}
+
+// flagNames lists the flags shown in the command's usage.
+func (c command) flagNames() []string {
+	var names []string
+	for _, f := range strings.FieldsFunc(c.args, func(r rune) bool { return r == ' ' || r == '[' || r == ']' }) {
+		if strings.HasPrefix(f, "-") {
+			names = append(names, f)
+		}
+	}
+	return names
+}
+
+// splitArgs splits a shell line into words. Single or double quotes group
+// words containing spaces, such as a city name.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShell(t *testing.T) {
+	addr := startServer(t)
+
+	script := strings.Join([]string{
+		"# comments and blank lines are skipped",
+		"",
+		"get 1",
+		"output csv",
+		`search -city "la"`,
+		"get 99",
+		"update 1",
+		"bogus",
+		"output",
+		"exit",
+		"get 2",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "shell"}, strings.NewReader(script), &stdout, &stderr)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "ID  FNAME  CITY  PHONE       HEIGHT  ISMARRIED  VERSION\n"+
+		"1   Steve  LA    9827329211  5.8     MARRIED    1\n"+
+		"id,fname,city,phone,height,isMarried,version\n"+
+		"1,Steve,LA,9827329211,5.8,MARRIED,1\n"+
+		"3,Alice,LA,9876545876,5.5,MARRIED,1\n"+
+		"csv\n", stdout.String())
+	assert.Contains(t, stderr.String(), "error: userclient: NotFound")
+	assert.Contains(t, stderr.String(), "error: update needs at least one field flag\nusage: update <id>")
+	assert.Contains(t, stderr.String(), `error: unknown command "bogus"`)
+}
+
+func TestShellHistory(t *testing.T) {
+	addr := startServer(t)
+
+	script := strings.Join([]string{
+		"output csv",
+		"history 1",
+		"history",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-addr", addr, "shell"}, strings.NewReader(script), &stdout, &stderr)
+
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stderr.String())
+	lines := strings.Split(stdout.String(), "\n")
+	if assert.Len(t, lines, 6) {
+		assert.Equal(t, "time,type,actor,version,changes", lines[0], "history with arguments should run the command")
+		assert.Contains(t, lines[1], ",CREATED,system,1,")
+		assert.Equal(t, []string{"   1  output csv", "   2  history 1", "   3  history", ""}, lines[2:])
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		pos      int
+		wantLine string
+		wantPos  int
+		wantOk   bool
+	}{
+		{
+			name:     "unique command",
+			line:     "sea",
+			pos:      3,
+			wantLine: "search ",
+			wantPos:  7,
+			wantOk:   true,
+		},
+		{
+			name:   "ambiguous command without common prefix",
+			line:   "h",
+			pos:    1,
+			wantOk: false,
+		},
+		{
+			name:     "flag of command",
+			line:     "update 1 -he",
+			pos:      12,
+			wantLine: "update 1 -height ",
+			wantPos:  17,
+			wantOk:   true,
+		},
+		{
+			name:     "marital status value",
+			line:     "search -married ma",
+			pos:      18,
+			wantLine: "search -married MARRIED ",
+			wantPos:  24,
+			wantOk:   true,
+		},
+		{
+			name:     "completes in the middle of a line",
+			line:     "search -ci LA",
+			pos:      10,
+			wantLine: "search -city LA",
+			wantPos:  12,
+			wantOk:   true,
+		},
+		{
+			name:   "no match",
+			line:   "get -x",
+			pos:    6,
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, pos, ok := complete(tt.line, tt.pos, '\t')
+
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.wantLine, line)
+				assert.Equal(t, tt.wantPos, pos)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "plain words",
+			line: "list  1 2\t3",
+			want: []string{"list", "1", "2", "3"},
+		},
+		{
+			name: "quoted words",
+			line: `search -city "New York" -phone '98'`,
+			want: []string{"search", "-city", "New York", "-phone", "98"},
+		},
+		{
+			name: "empty quotes",
+			line: `update 1 -city ""`,
+			want: []string{"update", "1", "-city", ""},
+		},
+		{
+			name:    "unterminated quote",
+			line:    `search -city "New York`,
+			wantErr: `unterminated " quote`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitArgs(tt.line)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+		})
+	}
+}
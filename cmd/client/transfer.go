@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"user-service-module/internal/userfile"
+	"user-service-module/pkg/userclient"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// importReport is the outcome of an import, combining rows that could not be
+// parsed locally with rows rejected by the server.
+type importReport struct {
+	Created uint32        `json:"created"`
+	Updated uint32        `json:"updated"`
+	Failed  uint32        `json:"failed"`
+	DryRun  bool          `json:"dryRun"`
+	Errors  []importError `json:"errors"`
+}
+
+type importError struct {
+	Line  uint32 `json:"line"`
+	Error string `json:"error"`
+}
+
+func runImport(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		return usagef("import takes the file before any flags")
+	}
+	path := args[0]
+
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "file format, ndjson or csv; guessed from the extension when empty")
+	upsert := fs.Bool("upsert", false, "replace users whose ID exists instead of failing the row")
+	dryRun := fs.Bool("dry-run", false, "validate every row and report what would change without writing")
+	reportPath := fs.String("report", "", "also write failed rows as CSV to this file")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *format == "" {
+		var err error
+		if *format, err = userfile.FormatFromPath(path); err != nil {
+			return usagef("import: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := userfile.NewReader(f, *format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Cancelling stops the stream when reading the file fails part way
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	importer, err := e.client.ImportUsers(ctx, userclient.ImportOptions{Upsert: *upsert, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	report := &importReport{DryRun: *dryRun}
+	for {
+		user, err := r.Read()
+		var rowErr *userfile.RowError
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.As(err, &rowErr) {
+			report.Errors = append(report.Errors, importError{Line: uint32(rowErr.Line), Error: rowErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := importer.Send(uint32(r.Line()), user); err != nil {
+			return err
+		}
+	}
+
+	resp, err := importer.Close()
+	if err != nil {
+		return err
+	}
+	report.merge(resp)
+
+	if *reportPath != "" {
+		if err := report.writeCSV(*reportPath); err != nil {
+			return err
+		}
+	}
+	if err := report.print(e); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return findingsf("%d of %d rows failed", report.Failed, report.Created+report.Updated+report.Failed)
+	}
+	return nil
+}
+
+func (r *importReport) merge(resp *pb.ImportUsersResponse) {
+	r.Created, r.Updated = resp.Created, resp.Updated
+	for _, e := range resp.Errors {
+		r.Errors = append(r.Errors, importError{Line: e.Line, Error: e.Error})
+	}
+	sort.SliceStable(r.Errors, func(i, j int) bool { return r.Errors[i].Line < r.Errors[j].Line })
+	r.Failed = uint32(len(r.Errors))
+}
+
+func (r *importReport) print(e *env) error {
+	if e.printer.format == "json" {
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(e.stdout, string(out))
+		return err
+	}
+
+	verb := ""
+	if r.DryRun {
+		verb = "would be "
+	}
+	fmt.Fprintf(e.stdout, "%d %screated, %d %supdated, %d failed\n", r.Created, verb, r.Updated, verb, r.Failed)
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tERROR")
+	for _, err := range r.Errors {
+		fmt.Fprintf(tw, "%d\t%s\n", err.Line, err.Error)
+	}
+	return tw.Flush()
+}
+
+func (r *importReport) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"line", "error"})
+	for _, err := range r.Errors {
+		w.Write([]string{strconv.FormatUint(uint64(err.Line), 10), err.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("export")
+	format := fs.String("format", "", "file format, ndjson or csv; guessed from -out, or ndjson for stdout")
+	out := fs.String("out", "-", `file to write, "-" for stdout`)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = userfile.FormatNDJSON
+		if *out != "-" {
+			var err error
+			if *format, err = userfile.FormatFromPath(*out); err != nil {
+				return usagef("export: %v", err)
+			}
+		}
+	}
+
+	if *format != userfile.FormatNDJSON && *format != userfile.FormatCSV {
+		return usagef("export: unknown format %q, want ndjson or csv", *format)
+	}
+
+	var dest io.Writer = e.stdout
+	var f *os.File
+	if *out != "-" {
+		var err error
+		if f, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer f.Close()
+		dest = f
+	}
+	w, err := userfile.NewWriter(dest, *format)
+	if err != nil {
+		return err
+	}
+
+	exporter, err := e.client.ExportUsers(ctx)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for {
+		user, err := exporter.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.Write(user); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if f != nil {
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "exported %d users to %s\n", n, *out)
+	}
+	return nil
+}
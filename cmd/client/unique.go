@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+)
+
+func runUniqueReport(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("unique-report")
+	fields := fs.String("fields", "", "comma-separated fields to check; every field that can be made unique when empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	violations, err := e.client.FindUniqueViolations(ctx, splitList(*fields)...)
+	if err != nil {
+		return err
+	}
+	if err := e.printer.printViolations(violations); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return findingsf("%d values are held by more than one user", len(violations))
+	}
+	return nil
+}
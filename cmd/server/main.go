@@ -1,25 +1,256 @@
 package main
 
 import (
-    "user-service-module/internal/server"
-    "log"
-    "net"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
-    "google.golang.org/grpc"
-    pb "user-service-module/proto/user/userpb"
+	"user-service-module/internal/admin"
+	"user-service-module/internal/audit"
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/internal/gdpr"
+	"user-service-module/internal/idempotency"
+	"user-service-module/internal/logging"
+	"user-service-module/internal/metrics"
+	"user-service-module/internal/ratelimit"
+	"user-service-module/internal/recovery"
+	"user-service-module/internal/redact"
+	"user-service-module/internal/server"
+	"user-service-module/internal/tenant"
+	"user-service-module/internal/tracing"
+
+	"google.golang.org/grpc"
+	pb "user-service-module/proto/user/userpb"
 )
 
 func main() {
-    lis, err := net.Listen("tcp", ":33001")
-    if err != nil {
-        log.Fatalf("failed to listen: %v", err)
-    }
-
-    s := grpc.NewServer()
-    pb.RegisterUserServiceServer(s, server.NewUserServer())
-
-    log.Printf("server listening at %v", lis.Addr())
-    if err := s.Serve(lis); err != nil {
-        log.Fatalf("failed to serve: %v", err)
-    }
+	addr := flag.String("addr", ":33001", "address the gRPC server listens on")
+	policyPath := flag.String("policy", "", "path to a JSON authorization policy; authorization is disabled when empty")
+	redactFields := flag.String("redact-fields", "phone,email,dateOfBirth,address", "comma-separated user fields masked for callers without pii:read, which is every caller without -policy; nothing is masked when empty")
+	rate := flag.Float64("rate", 0, "tokens per second refilled for each client; rate limiting is disabled when 0")
+	burst := flag.Float64("burst", 20, "maximum tokens a client can accumulate")
+	maxInflight := flag.Int("max-inflight", 0, "maximum concurrent requests across all clients; unlimited when 0")
+	metricsAddr := flag.String("metrics-addr", ":9090", "address serving Prometheus metrics on /metrics; disabled when empty")
+	traceDest := flag.String("trace", "", `export trace spans to "stdout" or a file path; tracing is disabled when empty`)
+	logFormat := flag.String("log-format", "text", `log output format, "text" or "json"`)
+	crashDir := flag.String("crash-dir", "", "directory receiving a report for every recovered panic; disabled when empty")
+	adminAddr := flag.String("admin-addr", "", "internal address serving pprof, health checks, build info and store stats; disabled when empty")
+	idempotencyTTL := flag.Duration("idempotency-ttl", idempotency.DefaultTTL, "how long responses to writes are kept for replay by idempotency key; disabled when 0")
+	auditPath := flag.String("audit-log", "", "file receiving a hash-chained record of every read of user data; auditing is disabled when empty")
+	archiveKey := flag.String("archive-key", "", "PEM file with the Ed25519 private key signing user data archives; a key is generated at startup when empty")
+	tenants := flag.String("tenants", "", "comma-separated tenants callers may act for; any valid tenant name is accepted when empty")
+	maxTenants := flag.Int("max-tenants", 100, "maximum tenants, the default tenant included, created by writes; unlimited when 0")
+	tenantMaxUsers := flag.Int("tenant-max-users", 0, "maximum users held by each tenant; unlimited when 0")
+	uniqueFields := flag.String("unique", "", "comma-separated user fields no two users of a tenant may share: "+strings.Join(server.UniqueFields(), ", "))
+	flag.Parse()
+
+	logger, err := logging.New(os.Stderr, *logFormat, slog.LevelInfo)
+	if err != nil {
+		fatal("invalid log configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	for _, name := range splitList(*uniqueFields) {
+		if !slices.Contains(server.UniqueFields(), name) {
+			fatal("invalid unique constraints", fmt.Errorf("unknown field %q, want one of %s", name, strings.Join(server.UniqueFields(), ", ")))
+		}
+	}
+
+	// Every tenant signs archives with the same key, so one public key
+	// verifies them all.
+	var key ed25519.PrivateKey
+	if *archiveKey != "" {
+		key, err = gdpr.LoadPrivateKey(*archiveKey)
+		if err != nil {
+			fatal("failed to load archive key", err)
+		}
+	} else {
+		var pub ed25519.PublicKey
+		if pub, key, err = ed25519.GenerateKey(nil); err != nil {
+			fatal("failed to generate archive key", err)
+		}
+		pubPEM, _ := gdpr.EncodePublicKey(pub)
+		slog.Warn("signing user data archives with a key generated at startup", "public_key", string(pubPEM))
+	}
+	var responses *idempotency.Store
+	if *idempotencyTTL > 0 {
+		responses = idempotency.NewStore(*idempotencyTTL, idempotency.DefaultMethods)
+	}
+	userServer := server.NewTenants(func(name string) *server.UserServer {
+		opts := []server.Option{server.WithTenant(name), server.WithArchiveKey(key), server.WithMaxUsers(*tenantMaxUsers), server.WithUniqueFields(splitList(*uniqueFields)...)}
+		if name != tenant.Default {
+			opts = append(opts, server.WithoutSampleUsers())
+		}
+		if *auditPath != "" {
+			opts = append(opts, server.WithAuditRecords(func(userID uint32) ([]json.RawMessage, error) {
+				return audit.FindLines(*auditPath, audit.Filter{Tenant: name, UserID: userID})
+			}))
+		}
+		if responses != nil {
+			opts = append(opts, server.WithResponseCache(func(userID uint32) int {
+				return responses.Forget(name, userID)
+			}))
+		}
+		return server.NewUserServer(opts...)
+	}, server.WithMaxTenants(*maxTenants))
+
+	var m *metrics.Metrics
+	recoverer := recovery.New(*crashDir, func(method string) {
+		if m != nil {
+			m.RecordPanic(method)
+		}
+	})
+	// A recoverer also runs outermost so a panic in an interceptor is
+	// recovered too; the innermost one catches handler panics first.
+	unary := []grpc.UnaryServerInterceptor{recoverer.UnaryServerInterceptor(), logging.UnaryServerInterceptor(logger)}
+	stream := []grpc.StreamServerInterceptor{recoverer.StreamServerInterceptor(), logging.StreamServerInterceptor(logger)}
+	if *traceDest != "" {
+		shutdown, err := tracing.Setup("user-service", *traceDest)
+		if err != nil {
+			fatal("failed to set up tracing", err)
+		}
+		defer shutdown(context.Background())
+		unary = append(unary, tracing.UnaryServerInterceptor())
+		stream = append(stream, tracing.StreamServerInterceptor())
+	}
+	if *metricsAddr != "" {
+		m = metrics.New()
+		m.RegisterStore(userServer)
+		unary = append(unary, m.UnaryServerInterceptor())
+		stream = append(stream, m.StreamServerInterceptor())
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		go func() {
+			slog.Info("metrics listening", "addr", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fatal("failed to serve metrics", err)
+			}
+		}()
+	}
+	// Without a policy no caller holds pii:read, so PII is masked for all
+	piiRead := func(ctx context.Context) bool { return false }
+	var crossTenant func(ctx context.Context) bool
+	if *policyPath != "" {
+		policy, err := auth.LoadPolicy(*policyPath)
+		if err != nil {
+			fatal("failed to load policy", err)
+		}
+		piiRead = func(ctx context.Context) bool {
+			return policy.Permitted(ctx, auth.PermPIIRead)
+		}
+		crossTenant = func(ctx context.Context) bool {
+			return policy.Permitted(ctx, auth.PermTenantsAny)
+		}
+		unary = append(unary, auth.UnaryServerInterceptor(policy))
+		stream = append(stream, auth.StreamServerInterceptor(policy))
+	}
+	// The tenant is resolved after authentication so tenant-bound identities
+	// cannot act for another tenant, and unbound ones only with tenants:any.
+	resolver := tenant.NewResolver(splitList(*tenants), crossTenant)
+	unary = append(unary, resolver.UnaryServerInterceptor())
+	stream = append(stream, resolver.StreamServerInterceptor())
+	if *rate > 0 || *maxInflight > 0 {
+		var limiter *ratelimit.Limiter
+		if *rate > 0 {
+			limiter = ratelimit.NewLimiter(*rate, *burst, ratelimit.DefaultCosts)
+		}
+		limits := ratelimit.NewInterceptors(limiter, *maxInflight)
+		unary = append(unary, limits.UnaryServerInterceptor())
+		stream = append(stream, limits.StreamServerInterceptor())
+	}
+	if *auditPath != "" {
+		auditLog, err := audit.Open(*auditPath)
+		if err != nil {
+			fatal("failed to open audit log", err)
+		}
+		defer auditLog.Close()
+		auditor := audit.NewAuditor(auditLog, audit.DefaultMethods)
+		unary = append(unary, auditor.UnaryServerInterceptor())
+		stream = append(stream, auditor.StreamServerInterceptor())
+	}
+	if responses != nil {
+		unary = append(unary, responses.UnaryServerInterceptor())
+	}
+	redactor, err := redact.NewRedactor(splitList(*redactFields), piiRead)
+	if err != nil {
+		fatal("failed to configure redaction", err)
+	}
+	if *redactFields == "" {
+		slog.Warn("serving PII in full since -redact-fields is empty")
+	}
+	unary = append(unary, redactor.UnaryServerInterceptor())
+	stream = append(stream, redactor.StreamServerInterceptor())
+
+	// Error mapping and recovery run innermost so every outer interceptor
+	// observes the final status code rather than a raw error or a panic.
+	unary = append(unary, errors.UnaryServerInterceptor(), recoverer.UnaryServerInterceptor())
+	stream = append(stream, errors.StreamServerInterceptor(), recoverer.StreamServerInterceptor())
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fatal("failed to listen", err)
+	}
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	pb.RegisterUserServiceServer(s, userServer)
+
+	var adminServer *admin.Server
+	if *adminAddr != "" {
+		adminServer = admin.New(userServer.Stats)
+		go func() {
+			slog.Info("admin listening", "addr", *adminAddr)
+			if err := http.ListenAndServe(*adminAddr, adminServer.Handler()); err != nil {
+				fatal("failed to serve admin endpoints", err)
+			}
+		}()
+	}
+
+	// Stop accepting new RPCs on SIGINT/SIGTERM and let in-flight ones finish
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		slog.Info("shutting down", "signal", sig.String())
+		if adminServer != nil {
+			adminServer.SetReady(false)
+		}
+		s.GracefulStop()
+	}()
+
+	slog.Info("server listening", "addr", lis.Addr().String())
+	if adminServer != nil {
+		adminServer.SetReady(true)
+	}
+	if err := s.Serve(lis); err != nil {
+		fatal("failed to serve", err)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// fatal logs err and exits the process.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"user-service-module/internal/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestReadiness(t *testing.T) {
+	s := New(server.NewUserServer().Stats)
+
+	assert.Equal(t, http.StatusOK, get(t, s.Handler(), "/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s.Handler(), "/readyz").Code)
+
+	s.SetReady(true)
+	assert.Equal(t, http.StatusOK, get(t, s.Handler(), "/readyz").Code)
+
+	s.SetReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s.Handler(), "/readyz").Code)
+}
+
+func TestBuildInfo(t *testing.T) {
+	rec := get(t, New(server.NewUserServer().Stats).Handler(), "/buildinfo")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var info BuildInfo
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.NotEmpty(t, info.Version)
+}
+
+func TestStats(t *testing.T) {
+	rec := get(t, New(server.NewUserServer().Stats).Handler(), "/debug/users/stats")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var stats map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, float64(3), stats["users"])
+	assert.Equal(t, float64(2), stats["city_index_keys"])
+}
+
+func TestPprof(t *testing.T) {
+	rec := get(t, New(server.NewUserServer().Stats).Handler(), "/debug/pprof/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,275 @@
+// Package audit records reads of user data in a tamper-evident log file.
+// Every record carries the hash of the previous one, so editing, removing or
+// reordering records breaks the chain and is caught by Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service-module/internal/tenant"
+)
+
+// Record is one audited call, stored as a line of JSON.
+type Record struct {
+	// Seq numbers records from 1 in the order they were written.
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Subject is the authenticated caller.
+	Subject string `json:"subject"`
+	// Tenant is the tenant the call acted for, empty for the default tenant.
+	Tenant    string `json:"tenant,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	// Method is the full gRPC method name.
+	Method string `json:"method"`
+	// Stream is StreamOpened or StreamClosed for the records of a stream,
+	// and empty for unary calls.
+	Stream string `json:"stream,omitempty"`
+	// Code is the gRPC status code the call ended with, empty when a stream
+	// is opened.
+	Code string `json:"code,omitempty"`
+	// UserIDs lists, in ascending order, the users whose data was returned.
+	UserIDs []uint32 `json:"userIds,omitempty"`
+	// PrevHash is the hash of the previous record, empty for the first.
+	PrevHash string `json:"prevHash"`
+	// Hash is the hex SHA-256 of the record with Hash left empty.
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash of r computed over its other fields.
+func (r Record) hash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends records to a file, continuing the chain of records already in
+// it. It is safe for concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	head string
+	now  func() time.Time
+}
+
+// Open opens the log at path for appending, creating it if needed. The last
+// record of an existing file is read to continue its chain; use Verify to
+// check the records before it.
+func Open(path string) (*Log, error) {
+	last, err := lastRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{f: f, seq: last.Seq, head: last.Hash, now: time.Now}, nil
+}
+
+// lastRecord returns the last record of the file at path, or an empty record
+// when the file is missing or empty.
+func lastRecord(path string) (Record, error) {
+	var last Record
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	}
+	if err != nil {
+		return last, err
+	}
+	defer f.Close()
+
+	err = Read(f, func(r Record) error {
+		last = r
+		return nil
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("audit: %s: %w", path, err)
+	}
+	return last, nil
+}
+
+// Append completes r with its sequence number, time and hashes, writes it
+// and returns it. The record is written with a single write call so
+// concurrent appends never interleave.
+func (l *Log) Append(r Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Time = l.now().UTC()
+	r.PrevHash = l.head
+	hash, err := r.hash()
+	if err != nil {
+		return Record{}, err
+	}
+	r.Hash = hash
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return Record{}, err
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return Record{}, fmt.Errorf("audit: write: %w", err)
+	}
+	l.seq, l.head = r.Seq, r.Hash
+	return r, nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Read calls fn with every record of r in order, skipping blank lines.
+func Read(r io.Reader, fn func(Record) error) error {
+	return readLines(r, func(_ []byte, rec Record) error { return fn(rec) })
+}
+
+// readLines calls fn with every record of r and the line it was parsed from.
+func readLines(r io.Reader, fn func([]byte, Record) error) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if b = bytes.TrimSpace(b); len(b) > 0 {
+			var rec Record
+			if err := json.Unmarshal(b, &rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := fn(b, rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Summary describes a verified log.
+type Summary struct {
+	Records uint64
+	// Head is the hash of the last record. Keeping a copy elsewhere also
+	// makes truncation of the log detectable.
+	Head string
+}
+
+// Verify checks that every record of r hashes to its stored hash and links
+// to the record before it. It returns the first break in the chain.
+func Verify(r io.Reader) (Summary, error) {
+	var s Summary
+	err := Read(r, func(rec Record) error {
+		if rec.Seq != s.Records+1 {
+			return fmt.Errorf("record %d follows record %d", rec.Seq, s.Records)
+		}
+		if rec.PrevHash != s.Head {
+			return fmt.Errorf("record %d does not link to the record before it", rec.Seq)
+		}
+		hash, err := rec.hash()
+		if err != nil {
+			return err
+		}
+		if hash != rec.Hash {
+			return fmt.Errorf("record %d was modified: hash %s, stored %s", rec.Seq, hash, rec.Hash)
+		}
+		s.Records, s.Head = rec.Seq, rec.Hash
+		return nil
+	})
+	return s, err
+}
+
+// Filter selects records. Zero fields match every record.
+type Filter struct {
+	Subject string
+	// Tenant matches records of this tenant; records without a tenant belong
+	// to the default tenant.
+	Tenant string
+	// Method matches the full method name or its last element, such as
+	// "GetUser".
+	Method string
+	// UserID matches records that returned this user.
+	UserID uint32
+	Since  time.Time
+	Until  time.Time
+}
+
+// Match reports whether r is selected by f.
+func (f Filter) Match(r Record) bool {
+	if f.Subject != "" && r.Subject != f.Subject {
+		return false
+	}
+	if f.Tenant != "" && recordTenant(r) != f.Tenant {
+		return false
+	}
+	if f.Method != "" && r.Method != f.Method && !strings.HasSuffix(r.Method, "/"+f.Method) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	if f.UserID == 0 {
+		return true
+	}
+	for _, id := range r.UserIDs {
+		if id == f.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTenant returns the tenant of r.
+func recordTenant(r Record) string {
+	if r.Tenant == "" {
+		return tenant.Default
+	}
+	return r.Tenant
+}
+
+// FindLines returns the records of the log at path selected by f, as the
+// lines stored in the log so their hashes can still be checked. A missing
+// file holds no records.
+func FindLines(path string, f Filter) ([]json.RawMessage, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []json.RawMessage
+	err = readLines(file, func(line []byte, r Record) error {
+		if f.Match(r) {
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("audit: %s: %w", path, err)
+	}
+	return lines, nil
+}
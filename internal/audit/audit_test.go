@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service-module/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeLog appends one record per subject to a new log and returns its path.
+func writeLog(t *testing.T, subjects ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	assert.NoError(t, err)
+	for _, s := range subjects {
+		_, err := log.Append(Record{Subject: s, Method: "/proto.UserService/GetUser", Code: "OK", UserIDs: []uint32{1}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+	return path
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestAppendChainsRecords(t *testing.T) {
+	path := writeLog(t, "alice", "bob")
+
+	// Reopening continues the chain
+	log, err := Open(path)
+	assert.NoError(t, err)
+	third, err := log.Append(Record{Subject: "carol"})
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+
+	var records []Record
+	assert.NoError(t, Read(bytes.NewReader(readFile(t, path)), func(r Record) error {
+		records = append(records, r)
+		return nil
+	}))
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, "", records[0].PrevHash)
+	assert.Equal(t, records[0].Hash, records[1].PrevHash)
+	assert.Equal(t, records[1].Hash, records[2].PrevHash)
+	assert.Equal(t, uint64(3), third.Seq)
+
+	summary, err := Verify(bytes.NewReader(readFile(t, path)))
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{Records: 3, Head: third.Hash}, summary)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(lines []string) []string
+		wantErr string
+	}{
+		{
+			name: "should detect an edited record",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"bob"`, `"mallory"`, 1)
+				return lines
+			},
+			wantErr: "line 2: record 2 was modified",
+		},
+		{
+			name: "should detect a removed record",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantErr: "line 2: record 3 follows record 1",
+		},
+		{
+			name: "should detect a rehashed record",
+			tamper: func(lines []string) []string {
+				var r Record
+				json.Unmarshal([]byte(lines[1]), &r)
+				r.Subject = "mallory"
+				r.Hash, _ = r.hash()
+				b, _ := json.Marshal(r)
+				lines[1] = string(b)
+				return lines
+			},
+			wantErr: "line 3: record 3 does not link to the record before it",
+		},
+		{
+			name: "should reject a malformed line",
+			tamper: func(lines []string) []string {
+				lines[0] = "{"
+				return lines
+			},
+			wantErr: "line 1:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, "alice", "bob", "carol")
+			lines := strings.Split(strings.TrimSpace(string(readFile(t, path))), "\n")
+
+			_, err := Verify(strings.NewReader(strings.Join(tt.tamper(lines), "\n")))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := Record{Subject: "alice", Tenant: "acme", Method: "/proto.UserService/ListUsers", Time: at, UserIDs: []uint32{1, 3}}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"should match the empty filter", Filter{}, true},
+		{"should match the subject", Filter{Subject: "alice"}, true},
+		{"should not match another subject", Filter{Subject: "bob"}, false},
+		{"should match the tenant", Filter{Tenant: "acme"}, true},
+		{"should not match another tenant", Filter{Tenant: tenant.Default}, false},
+		{"should match the short method name", Filter{Method: "ListUsers"}, true},
+		{"should match the full method name", Filter{Method: "/proto.UserService/ListUsers"}, true},
+		{"should not match another method", Filter{Method: "Users"}, false},
+		{"should match a returned user", Filter{UserID: 3}, true},
+		{"should not match another user", Filter{UserID: 2}, false},
+		{"should match from since", Filter{Since: at}, true},
+		{"should not match before since", Filter{Since: at.Add(time.Second)}, false},
+		{"should not match at until", Filter{Until: at}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(r))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/logging"
+	"user-service-module/internal/tenant"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultMethods are the RPCs returning user data.
+var DefaultMethods = []string{
+	pb.UserService_GetUser_FullMethodName,
+	pb.UserService_ListUsers_FullMethodName,
+	pb.UserService_SearchUsers_FullMethodName,
+	pb.UserService_GetUserHistory_FullMethodName,
+	pb.UserService_ExportUsers_FullMethodName,
+	pb.UserService_WatchUsers_FullMethodName,
+	pb.UserService_ExportUserData_FullMethodName,
+	pb.UserService_FindUniqueViolations_FullMethodName,
+}
+
+// Values of Record.Stream.
+const (
+	StreamOpened = "opened"
+	StreamClosed = "closed"
+)
+
+// Auditor records calls to a set of methods in a Log.
+type Auditor struct {
+	log     *Log
+	methods map[string]bool
+}
+
+// NewAuditor returns an Auditor recording calls to methods in log.
+func NewAuditor(log *Log, methods []string) *Auditor {
+	a := &Auditor{log: log, methods: make(map[string]bool, len(methods))}
+	for _, m := range methods {
+		a.methods[m] = true
+	}
+	return a
+}
+
+// UnaryServerInterceptor records every audited call with the users in its
+// response. Audit fails closed: if the record cannot be written the response
+// is withheld and the call fails with Internal.
+func (a *Auditor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !a.methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+
+		ids := make(map[uint32]struct{})
+		if msg, ok := resp.(proto.Message); ok && err == nil {
+			collectUserIDs(msg.ProtoReflect(), ids)
+		}
+		// The user of a data export is inside the archive
+		if r, ok := req.(*pb.ExportUserDataRequest); ok && err == nil {
+			ids[r.Id] = struct{}{}
+		}
+		if auditErr := a.record(ctx, info.FullMethod, "", err, ids); auditErr != nil {
+			return nil, auditErr
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records every audited stream when it is opened,
+// so long-lived streams such as WatchUsers show up while they run, and again
+// when it ends, with the users in all the messages it sent. A stream whose
+// opening cannot be recorded is refused.
+func (a *Auditor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !a.methods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		if auditErr := a.record(ss.Context(), info.FullMethod, StreamOpened, nil, nil); auditErr != nil {
+			return auditErr
+		}
+		stream := &auditedStream{ServerStream: ss, ids: make(map[uint32]struct{})}
+		err := handler(srv, stream)
+		if auditErr := a.record(ss.Context(), info.FullMethod, StreamClosed, err, stream.ids); auditErr != nil {
+			return auditErr
+		}
+		return err
+	}
+}
+
+// record appends the record of a call that ended with err, or of a stream
+// being opened.
+func (a *Auditor) record(ctx context.Context, method, stream string, err error, ids map[uint32]struct{}) error {
+	subject := auth.AnonymousSubject
+	if id, ok := auth.FromContext(ctx); ok {
+		subject = id.Subject
+	}
+	name := tenant.FromContext(ctx)
+	if name == tenant.Default {
+		name = ""
+	}
+	code := status.Code(err).String()
+	if stream == StreamOpened {
+		code = ""
+	}
+	_, appendErr := a.log.Append(Record{
+		Subject:   subject,
+		Tenant:    name,
+		RequestID: logging.RequestIDFromContext(ctx),
+		Method:    method,
+		Stream:    stream,
+		Code:      code,
+		UserIDs:   sortedIDs(ids),
+	})
+	if appendErr != nil {
+		logging.FromContext(ctx).Error("audit record not written", slog.Any("error", appendErr))
+		return status.Error(codes.Internal, "audit log unavailable")
+	}
+	return nil
+}
+
+type auditedStream struct {
+	grpc.ServerStream
+	mu  sync.Mutex
+	ids map[uint32]struct{}
+}
+
+func (s *auditedStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		s.mu.Lock()
+		collectUserIDs(msg.ProtoReflect(), s.ids)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+var (
+	userName            = (&pb.User{}).ProtoReflect().Descriptor().FullName()
+	uniqueViolationName = (&pb.UniqueViolation{}).ProtoReflect().Descriptor().FullName()
+)
+
+// collectUserIDs adds the IDs of the users found anywhere in m, including
+// the users sharing a value in a unique violation, to ids.
+func collectUserIDs(m protoreflect.Message, ids map[uint32]struct{}) {
+	switch m.Descriptor().FullName() {
+	case userName:
+		if id := m.Interface().(*pb.User).Id; id != 0 {
+			ids[id] = struct{}{}
+		}
+		return
+	case uniqueViolationName:
+		for _, id := range m.Interface().(*pb.UniqueViolation).Ids {
+			ids[id] = struct{}{}
+		}
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil || fd.IsMap():
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				collectUserIDs(list.Get(i).Message(), ids)
+			}
+		default:
+			collectUserIDs(v.Message(), ids)
+		}
+		return true
+	})
+}
+
+func sortedIDs(ids map[uint32]struct{}) []uint32 {
+	if len(ids) == 0 {
+		return nil
+	}
+	sorted := make([]uint32, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
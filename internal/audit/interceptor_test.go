@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"user-service-module/internal/auth"
+	serviceerrors "user-service-module/internal/errors"
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var alice = &auth.Identity{Subject: "alice"}
+
+// identify authenticates every unary call as alice.
+func identify(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(auth.NewContext(ctx, alice), req)
+}
+
+type identifiedStream struct {
+	grpc.ServerStream
+}
+
+func (s identifiedStream) Context() context.Context {
+	return auth.NewContext(s.ServerStream.Context(), alice)
+}
+
+// identifyStream authenticates every stream as alice.
+func identifyStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, identifiedStream{ss})
+}
+
+// dial serves a UserServer audited into log.
+func dial(t *testing.T, log *Log) pb.UserServiceClient {
+	t.Helper()
+	auditor := NewAuditor(log, DefaultMethods)
+	conn := grpctest.Dial(t, server.NewUserServer(),
+		grpc.ChainUnaryInterceptor(identify, auditor.UnaryServerInterceptor(), serviceerrors.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(identifyStream, auditor.StreamServerInterceptor(), serviceerrors.StreamServerInterceptor()),
+	)
+	return pb.NewUserServiceClient(conn)
+}
+
+func TestInterceptors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	assert.NoError(t, err)
+	defer log.Close()
+	client := dial(t, log)
+	ctx := context.Background()
+
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: 2})
+	assert.NoError(t, err)
+	_, err = client.ListUsers(ctx, &pb.ListUsersRequest{Ids: []uint32{3, 1}})
+	assert.NoError(t, err)
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.CreateUser(ctx, &pb.CreateUserRequest{User: &pb.User{Fname: "Carol", City: "SF", Phone: "9876543210", Height: 5.4}})
+	assert.NoError(t, err)
+	_, err = client.FindUniqueViolations(ctx, &pb.FindUniqueViolationsRequest{Fields: []string{"phone"}})
+	assert.NoError(t, err)
+	stream, err := client.ExportUsers(ctx, &pb.ExportUsersRequest{})
+	assert.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	assert.ErrorIs(t, err, io.EOF)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var records []Record
+	assert.NoError(t, Read(bytes.NewReader(data), func(r Record) error {
+		records = append(records, r)
+		return nil
+	}))
+
+	want := []Record{
+		{Method: pb.UserService_GetUser_FullMethodName, Code: "OK", UserIDs: []uint32{2}},
+		{Method: pb.UserService_ListUsers_FullMethodName, Code: "OK", UserIDs: []uint32{1, 3}},
+		{Method: pb.UserService_GetUser_FullMethodName, Code: "NotFound"},
+		{Method: pb.UserService_FindUniqueViolations_FullMethodName, Code: "OK", UserIDs: []uint32{2, 4}},
+		{Method: pb.UserService_ExportUsers_FullMethodName, Stream: StreamOpened},
+		{Method: pb.UserService_ExportUsers_FullMethodName, Stream: StreamClosed, Code: "OK", UserIDs: []uint32{1, 2, 3, 4}},
+	}
+	if !assert.Len(t, records, len(want), "writes should not be audited") {
+		return
+	}
+	for i, r := range records {
+		assert.Equal(t, "alice", r.Subject)
+		assert.Equal(t, want[i].Method, r.Method)
+		assert.Equal(t, want[i].Stream, r.Stream)
+		assert.Equal(t, want[i].Code, r.Code)
+		assert.Equal(t, want[i].UserIDs, r.UserIDs)
+	}
+
+	summary, err := Verify(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), summary.Records)
+}
+
+func TestStreamRecordedWhenOpened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	assert.NoError(t, err)
+	defer log.Close()
+	client := dial(t, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = client.WatchUsers(ctx, &pb.WatchUsersRequest{})
+	assert.NoError(t, err)
+
+	var lines []json.RawMessage
+	assert.Eventually(t, func() bool {
+		lines, err = FindLines(path, Filter{Method: "WatchUsers"})
+		return err == nil && len(lines) > 0
+	}, time.Second, 10*time.Millisecond, "a running stream should already be recorded")
+	if assert.Len(t, lines, 1) {
+		assert.Contains(t, string(lines[0]), `"stream":"opened"`)
+	}
+}
+
+func TestInterceptorFailsClosed(t *testing.T) {
+	log, err := Open(filepath.Join(t.TempDir(), "audit.log"))
+	assert.NoError(t, err)
+	client := dial(t, log)
+	log.Close()
+
+	resp, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// AnonymousSubject is the subject assigned to callers that present no token.
+const AnonymousSubject = "anonymous"
+
+// Identity describes an authenticated caller.
+type Identity struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	// Tenant binds the caller to one tenant. Callers without a tenant may
+	// act for any tenant they name.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller identity stored in ctx, if any.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// bearerToken extracts the token from the "authorization: Bearer <token>"
+// metadata entry of an incoming request.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
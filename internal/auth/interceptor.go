@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor authenticates the caller, enforces the policy for
+// the invoked method and stores the identity in the handler context.
+func UnaryServerInterceptor(p *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id, err := p.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Authorize(id, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+// Field permissions are checked on every message received from the client.
+func StreamServerInterceptor(p *Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id, err := p.Authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		if err := p.Authorize(id, info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{
+			ServerStream: ss,
+			ctx:          NewContext(ss.Context(), id),
+			policy:       p,
+			identity:     id,
+			method:       info.FullMethod,
+		})
+	}
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	policy   *Policy
+	identity *Identity
+	method   string
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.policy.authorizeFields(s.identity, s.method, m)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Permissions understood by the policy layer.
+const (
+	PermAll           = "*"
+	PermUsersRead     = "users:read"
+	PermUsersWrite    = "users:write"
+	PermUsersSearch   = "users:search"
+	PermSearchByPhone = "users:search:phone"
+	PermSearchByPII   = "users:search:pii"
+	PermPIIRead       = "pii:read"
+	PermUsersPrivacy  = "users:privacy"
+	PermAttributes    = "attributes:admin"
+	PermTenantsAny    = "tenants:any"
+)
+
+// MethodPermissions maps each UserService RPC to the permission required to call it.
+// Methods missing from this map are denied.
+var MethodPermissions = map[string]string{
+	pb.UserService_GetUser_FullMethodName:              PermUsersRead,
+	pb.UserService_ListUsers_FullMethodName:            PermUsersRead,
+	pb.UserService_SearchUsers_FullMethodName:          PermUsersSearch,
+	pb.UserService_CreateUser_FullMethodName:           PermUsersWrite,
+	pb.UserService_UpdateUser_FullMethodName:           PermUsersWrite,
+	pb.UserService_DeleteUser_FullMethodName:           PermUsersWrite,
+	pb.UserService_WatchUsers_FullMethodName:           PermUsersRead,
+	pb.UserService_ImportUsers_FullMethodName:          PermUsersWrite,
+	pb.UserService_ExportUsers_FullMethodName:          PermUsersRead,
+	pb.UserService_BatchCreateUsers_FullMethodName:     PermUsersWrite,
+	pb.UserService_GetUserHistory_FullMethodName:       PermUsersRead,
+	pb.UserService_ExportUserData_FullMethodName:       PermUsersPrivacy,
+	pb.UserService_EraseUser_FullMethodName:            PermUsersPrivacy,
+	pb.UserService_RegisterAttribute_FullMethodName:    PermAttributes,
+	pb.UserService_ListAttributes_FullMethodName:       PermUsersRead,
+	pb.UserService_DeleteAttribute_FullMethodName:      PermAttributes,
+	pb.UserService_FindUniqueViolations_FullMethodName: PermUsersRead,
+}
+
+// FieldPermissions maps request fields, by RPC and proto field name, to the
+// additional permission required when the caller sets that field.
+var FieldPermissions = map[string]map[string]string{
+	pb.UserService_SearchUsers_FullMethodName: {
+		"phone":       PermSearchByPhone,
+		"email":       PermSearchByPII,
+		"dateOfBirth": PermSearchByPII,
+	},
+}
+
+// Policy maps bearer tokens to identities and roles to permissions.
+type Policy struct {
+	// Roles lists the permissions granted to each role.
+	Roles map[string][]string `json:"roles"`
+	// Tokens maps a bearer token to the identity presenting it.
+	Tokens map[string]Identity `json:"tokens"`
+}
+
+// LoadPolicy reads a JSON policy file from path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	for role, perms := range p.Roles {
+		if len(perms) == 0 {
+			return nil, fmt.Errorf("parse policy %s: role %q grants no permissions", path, role)
+		}
+	}
+	return &p, nil
+}
+
+// Authenticate resolves the identity of the caller from the request metadata.
+// Callers without a token are treated as the anonymous role.
+func (p *Policy) Authenticate(ctx context.Context) (*Identity, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return &Identity{Subject: AnonymousSubject, Roles: []string{AnonymousSubject}}, nil
+	}
+	if id, found := p.Tokens[token]; found {
+		return &id, nil
+	}
+	return nil, errors.Errorf("%w: unknown token", errors.ErrUnauthenticated)
+}
+
+// HasPermission reports whether any of the identity's roles grants perm.
+func (p *Policy) HasPermission(id *Identity, perm string) bool {
+	for _, role := range id.Roles {
+		for _, granted := range p.Roles[role] {
+			if granted == perm || granted == PermAll {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permitted reports whether the identity stored in ctx has been granted perm.
+func (p *Policy) Permitted(ctx context.Context, perm string) bool {
+	id, ok := FromContext(ctx)
+	return ok && p.HasPermission(id, perm)
+}
+
+// Authorize checks that the identity may call method with the given request.
+func (p *Policy) Authorize(id *Identity, method string, req any) error {
+	perm, found := MethodPermissions[method]
+	if !found {
+		return errors.Errorf("%w: method %s is not allowed", errors.ErrPermissionDenied, method)
+	}
+	if !p.HasPermission(id, perm) {
+		return permissionDenied(perm)
+	}
+	return p.authorizeFields(id, method, req)
+}
+
+// authorizeFields checks the per-field permissions of a request message.
+func (p *Policy) authorizeFields(id *Identity, method string, req any) error {
+	fields, found := FieldPermissions[method]
+	msg, ok := req.(proto.Message)
+	if !found || !ok {
+		return nil
+	}
+
+	m := msg.ProtoReflect()
+	for name, perm := range fields {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd != nil && m.Has(fd) && !p.HasPermission(id, perm) {
+			return permissionDenied(perm)
+		}
+	}
+	return nil
+}
+
+func permissionDenied(perm string) error {
+	return errors.Errorf("%w: missing permission %q", errors.ErrPermissionDenied, perm)
+}
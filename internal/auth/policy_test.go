@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicy = `{
+	"roles": {
+		"admin": ["*"],
+		"support": ["users:read", "users:search", "users:search:phone"],
+		"reader": ["users:read", "users:search"]
+	},
+	"tokens": {
+		"admin-token": {"subject": "admin", "roles": ["admin"]},
+		"support-token": {"subject": "support", "roles": ["support"]},
+		"reader-token": {"subject": "reader", "roles": ["reader"]}
+	}
+}`
+
+func loadTestPolicy(t *testing.T) *Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(loadTestPolicy(t))
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		method      string
+		req         any
+		expectedErr codes.Code
+		errContains string
+	}{
+		{
+			name:   "should allow reader to get user",
+			ctx:    withToken("reader-token"),
+			method: pb.UserService_GetUser_FullMethodName,
+			req:    &pb.GetUserRequest{Id: 1},
+		},
+		{
+			name:   "should allow reader to search by city",
+			ctx:    withToken("reader-token"),
+			method: pb.UserService_SearchUsers_FullMethodName,
+			req:    &pb.SearchUsersRequest{City: "LA"},
+		},
+		{
+			name:        "should deny reader to search by phone",
+			ctx:         withToken("reader-token"),
+			method:      pb.UserService_SearchUsers_FullMethodName,
+			req:         &pb.SearchUsersRequest{Phone: "9876543210"},
+			expectedErr: codes.PermissionDenied,
+			errContains: PermSearchByPhone,
+		},
+		{
+			name:        "should deny support to search by email",
+			ctx:         withToken("support-token"),
+			method:      pb.UserService_SearchUsers_FullMethodName,
+			req:         &pb.SearchUsersRequest{Email: "bob@example.com"},
+			expectedErr: codes.PermissionDenied,
+			errContains: PermSearchByPII,
+		},
+		{
+			name:   "should allow reader to search by last name",
+			ctx:    withToken("reader-token"),
+			method: pb.UserService_SearchUsers_FullMethodName,
+			req:    &pb.SearchUsersRequest{Lname: "Smith"},
+		},
+		{
+			name:   "should allow support to search by phone",
+			ctx:    withToken("support-token"),
+			method: pb.UserService_SearchUsers_FullMethodName,
+			req:    &pb.SearchUsersRequest{Phone: "9876543210"},
+		},
+		{
+			name:   "should allow reader to list attributes",
+			ctx:    withToken("reader-token"),
+			method: pb.UserService_ListAttributes_FullMethodName,
+			req:    &pb.ListAttributesRequest{},
+		},
+		{
+			name:        "should deny support to register attributes",
+			ctx:         withToken("support-token"),
+			method:      pb.UserService_RegisterAttribute_FullMethodName,
+			req:         &pb.RegisterAttributeRequest{},
+			expectedErr: codes.PermissionDenied,
+			errContains: PermAttributes,
+		},
+		{
+			name:   "should allow admin any method",
+			ctx:    withToken("admin-token"),
+			method: pb.UserService_SearchUsers_FullMethodName,
+			req:    &pb.SearchUsersRequest{Phone: "9876543210"},
+		},
+		{
+			name:        "should deny anonymous caller",
+			ctx:         context.Background(),
+			method:      pb.UserService_GetUser_FullMethodName,
+			req:         &pb.GetUserRequest{Id: 1},
+			expectedErr: codes.PermissionDenied,
+			errContains: PermUsersRead,
+		},
+		{
+			name:        "should reject unknown token",
+			ctx:         withToken("bogus"),
+			method:      pb.UserService_GetUser_FullMethodName,
+			req:         &pb.GetUserRequest{Id: 1},
+			expectedErr: codes.Unauthenticated,
+		},
+		{
+			name:        "should deny unknown method",
+			ctx:         withToken("admin-token"),
+			method:      "/proto.UserService/DropAll",
+			req:         &pb.GetUserRequest{},
+			expectedErr: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotIdentity *Identity
+			handler := func(ctx context.Context, req any) (any, error) {
+				gotIdentity, _ = FromContext(ctx)
+				return "ok", nil
+			}
+			_, err := interceptor(tt.ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if tt.expectedErr != codes.OK {
+				assert.Equal(t, tt.expectedErr, status.Code(err))
+				assert.Contains(t, err.Error(), tt.errContains)
+				assert.Nil(t, gotIdentity)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, gotIdentity)
+		})
+	}
+}
+
+func TestPermissionDeniedNamesPermission(t *testing.T) {
+	p := loadTestPolicy(t)
+	err := p.Authorize(&Identity{Subject: "x", Roles: []string{"reader"}}, pb.UserService_SearchUsers_FullMethodName,
+		&pb.SearchUsersRequest{Phone: "9876543210"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, err.Error(), errors.ErrPermissionDenied.Error())
+	assert.Contains(t, err.Error(), `"users:search:phone"`)
+}
+
+func TestLoadPolicyRejectsEmptyRole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"roles": {"empty": []}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadPolicy(path)
+	assert.Error(t, err)
+}
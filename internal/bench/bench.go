@@ -0,0 +1,337 @@
+// Package bench drives load against a UserService and reports throughput,
+// latency percentiles and errors.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service-module/pkg/userclient"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Operations that can appear in a mix.
+const (
+	OpGet    = "get"
+	OpList   = "list"
+	OpSearch = "search"
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+var ops = []string{OpGet, OpList, OpSearch, OpCreate, OpUpdate, OpDelete}
+
+// Mix weighs how often each operation is picked.
+type Mix map[string]int
+
+// DefaultMix is a read-heavy workload.
+var DefaultMix = Mix{OpGet: 6, OpList: 3, OpSearch: 1}
+
+// ParseMix parses weights written as "get=6,list=3,search=1".
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(s, ",") {
+		op, weight, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid mix entry %q, want op=weight", part)
+		}
+		if !isOp(op) {
+			return nil, fmt.Errorf("unknown operation %q, want one of %s", op, strings.Join(ops, ", "))
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weight, op)
+		}
+		mix[op] += w
+	}
+	return mix, nil
+}
+
+func isOp(op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Config describes a benchmark run.
+type Config struct {
+	Mix Mix
+	// Duration is how long requests are sent for.
+	Duration time.Duration
+	// Concurrency is the number of requests in flight at once.
+	Concurrency int
+	// QPS caps the total request rate; zero sends as fast as Concurrency allows.
+	QPS float64
+	// IDs are the users read by get and list.
+	IDs []uint32
+	// ListSize is the number of IDs per list request.
+	ListSize int
+	// Cities are searched for by search.
+	Cities []string
+	// Seed makes the sequence of operations reproducible.
+	Seed int64
+}
+
+func (c Config) validate() error {
+	total := 0
+	for _, w := range c.Mix {
+		total += w
+	}
+	switch {
+	case total == 0:
+		return errors.New("mix has no operation with a positive weight")
+	case c.Duration <= 0:
+		return errors.New("duration must be positive")
+	case c.Concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case c.QPS < 0:
+		return errors.New("qps must not be negative")
+	case len(c.IDs) == 0 && (c.Mix[OpGet] > 0 || c.Mix[OpList] > 0):
+		return errors.New("get and list need at least one user ID")
+	case len(c.Cities) == 0 && c.Mix[OpSearch] > 0:
+		return errors.New("search needs at least one city")
+	}
+	return nil
+}
+
+// sample is the outcome of one request.
+type sample struct {
+	op      string
+	latency time.Duration
+	code    codes.Code
+}
+
+// Run sends requests through client as described by cfg until the duration
+// elapses or ctx is cancelled, and reports the results.
+func Run(ctx context.Context, client *userclient.Client, cfg Config) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.ListSize <= 0 {
+		cfg.ListSize = 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	// With a QPS target a pacer hands out one token per request; otherwise
+	// workers send back to back
+	var tokens <-chan struct{}
+	if cfg.QPS > 0 {
+		tokens = pace(ctx, cfg.QPS)
+	}
+
+	r := &runner{client: client, cfg: cfg, picker: newPicker(cfg.Mix)}
+	results := make([][]sample, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(cfg.Seed + int64(i)))
+			for {
+				if tokens != nil {
+					if _, ok := <-tokens; !ok {
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				s := r.do(ctx, rng)
+				// Requests cut short by the end of the run are not counted
+				if ctx.Err() != nil {
+					return
+				}
+				results[i] = append(results[i], s)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var samples []sample
+	for _, rs := range results {
+		samples = append(samples, rs...)
+	}
+	return newReport(cfg, time.Since(start), samples), nil
+}
+
+// pace sends on the returned channel at qps until ctx is done.
+func pace(ctx context.Context, qps float64) <-chan struct{} {
+	tokens := make(chan struct{})
+	go func() {
+		defer close(tokens)
+		interval := time.Duration(float64(time.Second) / qps)
+		next := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(next)):
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case tokens <- struct{}{}:
+			}
+			next = next.Add(interval)
+		}
+	}()
+	return tokens
+}
+
+// picker chooses operations according to their weights.
+type picker struct {
+	ops   []string
+	upper []int
+	total int
+}
+
+func newPicker(mix Mix) *picker {
+	p := &picker{}
+	for _, op := range ops {
+		if w := mix[op]; w > 0 {
+			p.total += w
+			p.ops = append(p.ops, op)
+			p.upper = append(p.upper, p.total)
+		}
+	}
+	return p
+}
+
+func (p *picker) pick(rng *rand.Rand) string {
+	n := rng.Intn(p.total)
+	return p.ops[sort.SearchInts(p.upper, n+1)]
+}
+
+type runner struct {
+	client *userclient.Client
+	cfg    Config
+	picker *picker
+
+	// created holds users made by this run. Updates and deletes only touch
+	// these so existing data is left alone.
+	mu      sync.Mutex
+	created []uint32
+}
+
+func (r *runner) do(ctx context.Context, rng *rand.Rand) sample {
+	op := r.picker.pick(rng)
+
+	var call func() error
+	switch op {
+	case OpGet:
+		id := r.cfg.IDs[rng.Intn(len(r.cfg.IDs))]
+		call = func() error { _, err := r.client.GetUser(ctx, id); return err }
+	case OpList:
+		ids := make([]uint32, r.cfg.ListSize)
+		for i := range ids {
+			ids[i] = r.cfg.IDs[rng.Intn(len(r.cfg.IDs))]
+		}
+		call = func() error { _, err := r.client.ListUsers(ctx, ids); return err }
+	case OpSearch:
+		city := r.cfg.Cities[rng.Intn(len(r.cfg.Cities))]
+		call = func() error { _, err := r.client.SearchUsers(ctx, userclient.SearchQuery{City: city}); return err }
+	case OpCreate:
+		user := randomUser(rng)
+		call = func() error { return r.create(ctx, user) }
+	case OpUpdate:
+		id, err := r.target(ctx, rng)
+		if err != nil {
+			return sample{op: op, code: codeOf(err)}
+		}
+		defer r.release(id)
+		user := &pb.User{Id: id, City: randomUser(rng).City}
+		call = func() error { _, err := r.client.UpdateUser(ctx, user, "city"); return err }
+	case OpDelete:
+		id, err := r.target(ctx, rng)
+		if err != nil {
+			return sample{op: op, code: codeOf(err)}
+		}
+		call = func() error { return r.client.DeleteUser(ctx, id) }
+	}
+
+	start := time.Now()
+	err := call()
+	return sample{op: op, latency: time.Since(start), code: codeOf(err)}
+}
+
+func (r *runner) create(ctx context.Context, user *pb.User) error {
+	created, err := r.client.CreateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	r.release(created.Id)
+	return nil
+}
+
+// target takes a user created by this run out of the pool, creating one
+// first when the pool is empty, so no other worker writes it concurrently.
+func (r *runner) target(ctx context.Context, rng *rand.Rand) (uint32, error) {
+	for {
+		r.mu.Lock()
+		if n := len(r.created); n > 0 {
+			i := rng.Intn(n)
+			id := r.created[i]
+			r.created[i] = r.created[n-1]
+			r.created = r.created[:n-1]
+			r.mu.Unlock()
+			return id, nil
+		}
+		r.mu.Unlock()
+
+		if err := r.create(ctx, randomUser(rng)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// release returns a user to the pool.
+func (r *runner) release(id uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, id)
+}
+
+var benchCities = []string{"LA", "NY", "SF", "Chicago", "Boston", "Seattle"}
+
+func randomUser(rng *rand.Rand) *pb.User {
+	return &pb.User{
+		Fname:     "Bench",
+		City:      benchCities[rng.Intn(len(benchCities))],
+		Phone:     fmt.Sprintf("9%09d", rng.Intn(1e9)),
+		Height:    5 + float32(rng.Intn(20))/10,
+		IsMarried: pb.MaritalStatus(1 + rng.Intn(2)),
+	}
+}
+
+// codeOf returns the status code of a client error.
+func codeOf(err error) codes.Code {
+	var clientErr *userclient.Error
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.As(err, &clientErr):
+		return clientErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	default:
+		return codes.Unknown
+	}
+}
@@ -0,0 +1,150 @@
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	serviceerrors "user-service-module/internal/errors"
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/server"
+	"user-service-module/pkg/userclient"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func newClient(t *testing.T) *userclient.Client {
+	t.Helper()
+	conn := grpctest.Dial(t, server.NewUserServer(), grpc.UnaryInterceptor(serviceerrors.UnaryServerInterceptor()))
+	return userclient.NewFromConn(conn, userclient.WithoutRetry())
+}
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		name    string
+		mix     string
+		want    Mix
+		wantErr string
+	}{
+		{
+			name: "weights",
+			mix:  "get=6, list=3,search=1",
+			want: Mix{OpGet: 6, OpList: 3, OpSearch: 1},
+		},
+		{
+			name: "repeated operation adds up",
+			mix:  "create=1,create=2",
+			want: Mix{OpCreate: 3},
+		},
+		{
+			name:    "unknown operation",
+			mix:     "get=1,rename=2",
+			wantErr: `unknown operation "rename", want one of get, list, search, create, update, delete`,
+		},
+		{
+			name:    "missing weight",
+			mix:     "get",
+			wantErr: `invalid mix entry "get", want op=weight`,
+		},
+		{
+			name:    "negative weight",
+			mix:     "get=-1",
+			wantErr: `invalid weight "-1" for get`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mix, err := ParseMix(tt.mix)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, mix)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	client := newClient(t)
+	cfg := Config{
+		Mix:         Mix{OpGet: 3, OpList: 2, OpSearch: 1, OpCreate: 2, OpUpdate: 1, OpDelete: 1},
+		Duration:    200 * time.Millisecond,
+		Concurrency: 4,
+		IDs:         []uint32{1, 2, 3},
+		ListSize:    2,
+		Cities:      []string{"LA"},
+	}
+
+	report, err := Run(context.Background(), client, cfg)
+
+	assert.NoError(t, err)
+	assert.Positive(t, report.Requests)
+	assert.Zero(t, report.Errors, report.ErrorCodes)
+	assert.Positive(t, report.Throughput)
+	assert.LessOrEqual(t, report.Latency.P50, report.Latency.P99)
+	assert.LessOrEqual(t, report.Latency.P99, report.Latency.Max)
+
+	var ops []string
+	total := 0
+	for _, op := range report.Ops {
+		ops = append(ops, op.Op)
+		total += op.Requests
+	}
+	assert.Equal(t, []string{OpGet, OpList, OpSearch, OpCreate, OpUpdate, OpDelete}, ops)
+	assert.Equal(t, report.Requests, total)
+}
+
+func TestRunQPS(t *testing.T) {
+	client := newClient(t)
+	cfg := Config{
+		Mix:         DefaultMix,
+		Duration:    300 * time.Millisecond,
+		Concurrency: 4,
+		QPS:         50,
+		IDs:         []uint32{1},
+		Cities:      []string{"LA"},
+	}
+
+	report, err := Run(context.Background(), client, cfg)
+
+	assert.NoError(t, err)
+	// 50/s for 300ms, allowing for the first request being sent at once
+	assert.InDelta(t, 15, report.Requests, 3)
+}
+
+func TestRunErrors(t *testing.T) {
+	client := newClient(t)
+	cfg := Config{
+		Mix:         Mix{OpGet: 1},
+		Duration:    100 * time.Millisecond,
+		Concurrency: 1,
+		IDs:         []uint32{99},
+	}
+
+	report, err := Run(context.Background(), client, cfg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, report.Requests, report.Errors)
+	assert.Equal(t, map[string]int{"NotFound": report.Requests}, report.ErrorCodes)
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	_, err := Run(context.Background(), nil, Config{Mix: Mix{OpGet: 1}, Duration: time.Second, Concurrency: 1})
+
+	assert.EqualError(t, err, "get and list need at least one user ID")
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+
+	assert.Equal(t, time.Duration(50), percentile(sorted, 50))
+	assert.Equal(t, time.Duration(99), percentile(sorted, 99))
+	assert.Equal(t, time.Duration(1), percentile(sorted[:1], 99))
+}
@@ -0,0 +1,169 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Report summarizes a benchmark run.
+type Report struct {
+	Duration    time.Duration `json:"duration_ns"`
+	Concurrency int           `json:"concurrency"`
+	TargetQPS   float64       `json:"target_qps,omitempty"`
+	Requests    int           `json:"requests"`
+	Errors      int           `json:"errors"`
+	// Throughput is completed requests per second.
+	Throughput float64 `json:"throughput"`
+	Latency    Latency `json:"latency"`
+	// ErrorCodes counts failed requests by gRPC status code.
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+	Ops        []OpReport     `json:"ops"`
+}
+
+// OpReport summarizes the requests of one operation.
+type OpReport struct {
+	Op         string         `json:"op"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Latency    Latency        `json:"latency"`
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// Latency holds request latency statistics.
+type Latency struct {
+	Mean time.Duration `json:"mean_ns"`
+	P50  time.Duration `json:"p50_ns"`
+	P90  time.Duration `json:"p90_ns"`
+	P99  time.Duration `json:"p99_ns"`
+	Max  time.Duration `json:"max_ns"`
+}
+
+func newReport(cfg Config, elapsed time.Duration, samples []sample) *Report {
+	r := &Report{
+		Duration:    elapsed,
+		Concurrency: cfg.Concurrency,
+		TargetQPS:   cfg.QPS,
+		Requests:    len(samples),
+		Latency:     latencyOf(samples),
+		ErrorCodes:  errorCodes(samples),
+		Throughput:  float64(len(samples)) / elapsed.Seconds(),
+	}
+
+	byOp := make(map[string][]sample)
+	for _, s := range samples {
+		byOp[s.op] = append(byOp[s.op], s)
+	}
+	for _, op := range ops {
+		opSamples, found := byOp[op]
+		if !found {
+			continue
+		}
+		opCodes := errorCodes(opSamples)
+		errs := 0
+		for _, n := range opCodes {
+			errs += n
+		}
+		r.Errors += errs
+		r.Ops = append(r.Ops, OpReport{Op: op, Requests: len(opSamples), Errors: errs, Latency: latencyOf(opSamples), ErrorCodes: opCodes})
+	}
+	return r
+}
+
+func latencyOf(samples []sample) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+	latencies := make([]time.Duration, len(samples))
+	var total time.Duration
+	for i, s := range samples {
+		latencies[i] = s.latency
+		total += s.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	return Latency{
+		Mean: total / time.Duration(len(latencies)),
+		P50:  percentile(latencies, 50),
+		P90:  percentile(latencies, 90),
+		P99:  percentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// percentile uses the nearest-rank method on sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func errorCodes(samples []sample) map[string]int {
+	var counts map[string]int
+	for _, s := range samples {
+		if s.code == codes.OK {
+			continue
+		}
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		counts[s.code.String()]++
+	}
+	return counts
+}
+
+// WriteText writes the report as a human-readable summary.
+func (r *Report) WriteText(w io.Writer) error {
+	target := "unlimited"
+	if r.TargetQPS > 0 {
+		target = fmt.Sprintf("%g/s", r.TargetQPS)
+	}
+	fmt.Fprintf(w, "Duration:     %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Concurrency:  %d\n", r.Concurrency)
+	fmt.Fprintf(w, "Target QPS:   %s\n", target)
+	fmt.Fprintf(w, "Requests:     %d (%d errors)\n", r.Requests, r.Errors)
+	fmt.Fprintf(w, "Throughput:   %.1f/s\n\n", r.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OP\tREQUESTS\tERRORS\tMEAN\tP50\tP90\tP99\tMAX\t")
+	for _, op := range r.Ops {
+		writeRow(tw, op.Op, op.Requests, op.Errors, op.Latency)
+	}
+	writeRow(tw, "all", r.Requests, r.Errors, r.Latency)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.ErrorCodes) > 0 {
+		fmt.Fprintf(w, "\nErrors:\n")
+		for _, code := range sortedKeys(r.ErrorCodes) {
+			fmt.Fprintf(w, "  %-20s %d\n", code, r.ErrorCodes[code])
+		}
+	}
+	return nil
+}
+
+func writeRow(w io.Writer, name string, requests, errs int, l Latency) {
+	cells := []string{name, fmt.Sprint(requests), fmt.Sprint(errs)}
+	for _, d := range []time.Duration{l.Mean, l.P50, l.P90, l.P99, l.Max} {
+		cells = append(cells, formatLatency(d))
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t")+"\t")
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,171 @@
+// Package datagen generates realistic synthetic users for load and
+// performance testing.
+package datagen
+
+import (
+	"math"
+	"math/rand"
+
+	pb "user-service-module/proto/user/userpb"
+)
+
+// Phone numbers are 10 digits with a leading 2-9, like a US area code.
+const (
+	phoneBase  = 2_000_000_000
+	phoneSpace = 8_000_000_000
+)
+
+var firstNames = []string{
+	"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda",
+	"David", "Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica",
+	"Thomas", "Sarah", "Charles", "Karen", "Daniel", "Lisa", "Matthew", "Nancy",
+	"Anthony", "Betty", "Mark", "Sandra", "Steven", "Ashley", "Andrew", "Emily",
+	"Joshua", "Michelle", "Kevin", "Amanda", "Brian", "Melissa", "George", "Stephanie",
+	"Timothy", "Rebecca", "Ronald", "Laura", "Jason", "Sharon", "Ryan", "Cynthia",
+	"Mary-Jane", "Jean-Luc", "Sean", "Aisha", "Wei", "Priya", "Carlos", "Sofia",
+	"Mohammed", "Yuki", "Olga", "Kwame",
+}
+
+// cities are weighted roughly by population so common cities dominate, as
+// they do in real data.
+var cities = []struct {
+	name   string
+	weight int
+}{
+	{"New York", 84}, {"Los Angeles", 39}, {"Chicago", 27}, {"Houston", 23},
+	{"Phoenix", 16}, {"Philadelphia", 16}, {"San Antonio", 15}, {"San Diego", 14},
+	{"Dallas", 13}, {"Austin", 10}, {"Jacksonville", 9}, {"San Francisco", 8},
+	{"Columbus", 9}, {"Seattle", 7}, {"Denver", 7}, {"Boston", 7},
+	{"Nashville", 7}, {"Portland", 6}, {"Las Vegas", 6}, {"Detroit", 6},
+	{"Salt Lake City", 2}, {"Winston-Salem", 2}, {"LA", 3}, {"NY", 3},
+}
+
+// Marital status shares, with a few users who never said.
+var maritalStatuses = []struct {
+	status pb.MaritalStatus
+	weight int
+}{
+	{pb.MaritalStatus_MARRIED, 50},
+	{pb.MaritalStatus_SINGLE, 45},
+	{pb.MaritalStatus_UNKNOWN, 5},
+}
+
+// Heights in feet follow a normal distribution clamped to a plausible range.
+const (
+	heightMean   = 5.6
+	heightStdDev = 0.35
+	heightMin    = 4.5
+	heightMax    = 7.0
+)
+
+// Generator produces users deterministically from a seed. It is not safe for
+// concurrent use.
+type Generator struct {
+	rng         *rand.Rand
+	n           int64
+	phoneOffset int64
+	phoneStep   int64
+	cityTotal   int
+	statusTotal int
+}
+
+// NewGenerator returns a generator whose sequence of users depends only on
+// seed.
+func NewGenerator(seed int64) *Generator {
+	rng := rand.New(rand.NewSource(seed))
+	g := &Generator{
+		rng:         rng,
+		phoneOffset: rng.Int63n(phoneSpace),
+		phoneStep:   phoneStep(rng),
+	}
+	for _, c := range cities {
+		g.cityTotal += c.weight
+	}
+	for _, s := range maritalStatuses {
+		g.statusTotal += s.weight
+	}
+	return g
+}
+
+// phoneStep picks a step coprime with phoneSpace, so stepping through the
+// space visits every number once and phones stay unique.
+func phoneStep(rng *rand.Rand) int64 {
+	for {
+		step := rng.Int63n(phoneSpace/2) + phoneSpace/4
+		if step%2 != 0 && step%5 != 0 {
+			return step
+		}
+	}
+}
+
+// Next returns the next user. IDs count up from 1 and phone numbers are
+// unique for the first 8 billion users.
+func (g *Generator) Next() *pb.User {
+	g.n++
+	return &pb.User{
+		Id:        uint32(g.n),
+		Fname:     firstNames[g.rng.Intn(len(firstNames))],
+		City:      g.city(),
+		Phone:     g.phone(),
+		Height:    g.height(),
+		IsMarried: g.maritalStatus(),
+	}
+}
+
+func (g *Generator) city() string {
+	n := g.rng.Intn(g.cityTotal)
+	for _, c := range cities {
+		if n < c.weight {
+			return c.name
+		}
+		n -= c.weight
+	}
+	return cities[len(cities)-1].name
+}
+
+func (g *Generator) phone() string {
+	// Multiplying the index modulo the space scatters consecutive users
+	// across it
+	n := (g.phoneOffset + mulMod(g.n, g.phoneStep, phoneSpace)) % phoneSpace
+	return formatPhone(phoneBase + n)
+}
+
+func (g *Generator) height() float32 {
+	h := g.rng.NormFloat64()*heightStdDev + heightMean
+	h = math.Max(heightMin, math.Min(heightMax, h))
+	return float32(math.Round(h*10) / 10)
+}
+
+func (g *Generator) maritalStatus() pb.MaritalStatus {
+	n := g.rng.Intn(g.statusTotal)
+	for _, s := range maritalStatuses {
+		if n < s.weight {
+			return s.status
+		}
+		n -= s.weight
+	}
+	return pb.MaritalStatus_UNKNOWN
+}
+
+// mulMod returns a*b mod m without overflowing for a, b < m < 2^34.
+func mulMod(a, b, m int64) int64 {
+	a %= m
+	var result int64
+	for b > 0 {
+		if b&1 == 1 {
+			result = (result + a) % m
+		}
+		a = (a * 2) % m
+		b >>= 1
+	}
+	return result
+}
+
+func formatPhone(n int64) string {
+	var buf [10]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return string(buf[:])
+}
@@ -0,0 +1,106 @@
+package datagen
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	serviceerrors "user-service-module/internal/errors"
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/server"
+	"user-service-module/internal/userfile"
+	"user-service-module/internal/utils"
+	"user-service-module/pkg/userclient"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGeneratorUsersAreValid(t *testing.T) {
+	g := NewGenerator(1)
+	phones := make(map[string]bool)
+	statuses := make(map[pb.MaritalStatus]int)
+
+	const n = 20000
+	for i := 1; i <= n; i++ {
+		u := g.Next()
+		assert.NoError(t, utils.ValidateUser(u))
+		assert.Equal(t, uint32(i), u.Id)
+		assert.False(t, phones[u.Phone], "duplicate phone %s", u.Phone)
+		phones[u.Phone] = true
+		statuses[u.IsMarried]++
+	}
+
+	assert.InDelta(t, 0.50, float64(statuses[pb.MaritalStatus_MARRIED])/n, 0.02)
+	assert.InDelta(t, 0.45, float64(statuses[pb.MaritalStatus_SINGLE])/n, 0.02)
+	assert.InDelta(t, 0.05, float64(statuses[pb.MaritalStatus_UNKNOWN])/n, 0.01)
+}
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	a, b, c := NewGenerator(42), NewGenerator(42), NewGenerator(43)
+
+	var sameAsOtherSeed int
+	for i := 0; i < 100; i++ {
+		ua, ub, uc := a.Next(), b.Next(), c.Next()
+		assert.Equal(t, ua.String(), ub.String())
+		if ua.Phone == uc.Phone {
+			sameAsOtherSeed++
+		}
+	}
+	assert.Zero(t, sameAsOtherSeed)
+}
+
+func TestMulMod(t *testing.T) {
+	assert.Equal(t, int64(6), mulMod(2, 3, 7))
+	// (m-1)^2 = 1 mod m, where a plain product would overflow
+	assert.Equal(t, int64(1), mulMod(phoneSpace-1, phoneSpace-1, phoneSpace))
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("ndjson", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, NewGenerator(1), 3, userfile.FormatNDJSON))
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		assert.Len(t, lines, 3)
+		want := NewGenerator(1)
+		for _, line := range lines {
+			var u pb.User
+			assert.NoError(t, protojson.Unmarshal([]byte(line), &u))
+			assert.Equal(t, want.Next().String(), u.String())
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, NewGenerator(1), 3, userfile.FormatCSV))
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 4)
+		assert.Equal(t, userfile.Columns, records[0])
+		want := NewGenerator(1).Next()
+		assert.Equal(t, []string{"1", want.Fname, want.City, want.Phone}, records[1][:4])
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		err := Write(&bytes.Buffer{}, NewGenerator(1), 3, "xml")
+
+		assert.EqualError(t, err, `unknown format "xml", want ndjson or csv`)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	srv := server.NewUserServer()
+	conn := grpctest.Dial(t, srv, grpc.UnaryInterceptor(serviceerrors.UnaryServerInterceptor()))
+
+	created, err := Load(context.Background(), userclient.NewFromConn(conn), NewGenerator(1), 500)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 500, created)
+	assert.Equal(t, 503, srv.Stats().Users)
+}
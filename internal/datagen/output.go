@@ -0,0 +1,61 @@
+package datagen
+
+import (
+	"context"
+	"io"
+
+	"user-service-module/internal/userfile"
+	"user-service-module/pkg/userclient"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// Write writes the next n users from g to w in the given userfile format.
+func Write(w io.Writer, g *Generator, n int, format string) error {
+	uw, err := userfile.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := uw.Write(g.Next()); err != nil {
+			return err
+		}
+	}
+	return uw.Flush()
+}
+
+// loadChunk is the number of users generated and sent per CreateUsers call,
+// bounding memory while loading millions of users.
+const loadChunk = 10000
+
+// Load creates the next n users from g through client in batches over
+// streaming calls. The server assigns IDs. It stops after the first chunk
+// with a failed user and returns how many users were created.
+func Load(ctx context.Context, client *userclient.Client, g *Generator, n int) (int, error) {
+	created := 0
+	for created < n {
+		users := make([]*pb.User, min(loadChunk, n-created))
+		for i := range users {
+			users[i] = g.Next()
+			users[i].Id = 0
+		}
+
+		results, err := client.CreateUsers(ctx, users)
+		if err != nil {
+			return created, err
+		}
+		var firstErr error
+		for _, r := range results {
+			if r.Err != nil {
+				if firstErr == nil {
+					firstErr = r.Err
+				}
+				continue
+			}
+			created++
+		}
+		if firstErr != nil {
+			return created, firstErr
+		}
+	}
+	return created, nil
+}
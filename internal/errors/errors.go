@@ -8,4 +8,14 @@ var (
 	ErrInvalidID = errors.New("error: invalid ID(s)")
 	ErrUserNotFound = errors.New("error: user(s) not found")
 	ErrInvalidFields = errors.New("error: invalid field(s)")
-)
\ No newline at end of file
+	ErrUnauthenticated = errors.New("error: unauthenticated")
+	ErrPermissionDenied = errors.New("error: permission denied")
+	ErrWatchOverflow = errors.New("error: watcher fell too far behind")
+	ErrUserExists = errors.New("error: user(s) already exist")
+	ErrVersionMismatch = errors.New("error: version mismatch")
+	ErrIdempotencyKeyReused = errors.New("error: idempotency key reused with a different request")
+	ErrQuotaExceeded = errors.New("error: user quota exceeded")
+	ErrAttributeNotFound = errors.New("error: attribute(s) not registered")
+	ErrAttributeInUse = errors.New("error: attribute in use")
+	ErrUniqueViolation = errors.New("error: unique constraint violated")
+)
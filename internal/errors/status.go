@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service-module/pkg/userapi"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code returns the gRPC status code matching a service error.
+func Code(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidFields), errors.Is(err, ErrIdempotencyKeyReused):
+		return codes.InvalidArgument
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrAttributeNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, ErrPermissionDenied):
+		return codes.PermissionDenied
+	case errors.Is(err, ErrWatchOverflow), errors.Is(err, ErrQuotaExceeded):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrUserExists), errors.Is(err, ErrUniqueViolation):
+		return codes.AlreadyExists
+	case errors.Is(err, ErrVersionMismatch), errors.Is(err, ErrAttributeInUse):
+		return codes.FailedPrecondition
+	default:
+		return status.Code(err)
+	}
+}
+
+// reasons maps service errors to the reason of the ErrorInfo detail sent
+// with them, so clients need not parse messages.
+var reasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrInvalidID, userapi.ReasonInvalidID},
+	{ErrInvalidFields, userapi.ReasonInvalidFields},
+	{ErrUserNotFound, userapi.ReasonUserNotFound},
+	{ErrUserExists, userapi.ReasonUserExists},
+	{ErrVersionMismatch, userapi.ReasonVersionMismatch},
+	{ErrUnauthenticated, userapi.ReasonUnauthenticated},
+	{ErrPermissionDenied, userapi.ReasonPermissionDenied},
+	{ErrIdempotencyKeyReused, userapi.ReasonIdempotencyKeyReused},
+	{ErrQuotaExceeded, userapi.ReasonQuotaExceeded},
+	{ErrAttributeNotFound, userapi.ReasonAttributeNotFound},
+	{ErrAttributeInUse, userapi.ReasonAttributeInUse},
+	{ErrUniqueViolation, userapi.ReasonUniqueViolation},
+	{ErrWatchOverflow, userapi.ReasonWatchOverflow},
+}
+
+// Reason returns the ErrorInfo reason of a service error, or "" for other
+// errors.
+func Reason(err error) string {
+	for _, r := range reasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return ""
+}
+
+// ToStatus converts a service error into a gRPC status error carrying the
+// matching code and ErrorInfo reason. Errors that already carry a status are
+// returned unchanged.
+func ToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return WithReason(status.New(Code(err), err.Error()), Reason(err))
+}
+
+// Errorf formats a service error like fmt.Errorf and converts it with
+// ToStatus, for interceptors running outside UnaryServerInterceptor.
+func Errorf(format string, args ...any) error {
+	return ToStatus(fmt.Errorf(format, args...))
+}
+
+// WithReason returns st as an error carrying an ErrorInfo detail with reason,
+// or without one when reason is empty.
+func WithReason(st *status.Status, reason string) error {
+	if reason == "" {
+		return st.Err()
+	}
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: userapi.ErrorDomain})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
+// UnaryServerInterceptor maps handler errors to gRPC status codes.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, ToStatus(err)
+	}
+}
+
+// StreamServerInterceptor maps stream handler errors to gRPC status codes.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ToStatus(handler(srv, ss))
+	}
+}
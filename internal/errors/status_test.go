@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"user-service-module/pkg/userapi"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"should map invalid ID", fmt.Errorf("%w: %d", ErrInvalidID, 0), codes.InvalidArgument},
+		{"should map invalid fields", fmt.Errorf("%w: city", ErrInvalidFields), codes.InvalidArgument},
+		{"should map missing users", fmt.Errorf("%w: [999]", ErrUserNotFound), codes.NotFound},
+		{"should map existing users", fmt.Errorf("%w: 1", ErrUserExists), codes.AlreadyExists},
+		{"should map reused idempotency keys", fmt.Errorf("%w: key first used for CreateUser", ErrIdempotencyKeyReused), codes.InvalidArgument},
+		{"should map version mismatches", fmt.Errorf("%w: user 1 is at version 2, want 1", ErrVersionMismatch), codes.FailedPrecondition},
+		{"should map unregistered attributes", fmt.Errorf("%w: team", ErrAttributeNotFound), codes.NotFound},
+		{"should map attributes in use", fmt.Errorf("%w: 2 users have a value for team", ErrAttributeInUse), codes.FailedPrecondition},
+		{"should map unique constraint violations", fmt.Errorf("%w: phone is taken by user 2", ErrUniqueViolation), codes.AlreadyExists},
+		{"should map exceeded quotas", fmt.Errorf("%w: the store holds at most 10 users", ErrQuotaExceeded), codes.ResourceExhausted},
+		{"should keep existing status", status.Error(codes.ResourceExhausted, "slow down"), codes.ResourceExhausted},
+		{"should default to unknown", fmt.Errorf("boom"), codes.Unknown},
+		{"should keep nil", nil, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ToStatus(tt.err)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.err != nil {
+				assert.Contains(t, tt.err.Error(), status.Convert(err).Message())
+			}
+		})
+	}
+}
+
+func TestToStatusReason(t *testing.T) {
+	err := ToStatus(fmt.Errorf("%w: 2 events dropped", ErrWatchOverflow))
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		info := details[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, userapi.ReasonWatchOverflow, info.Reason)
+		assert.Equal(t, userapi.ErrorDomain, info.Domain)
+	}
+
+	assert.Empty(t, status.Convert(ToStatus(fmt.Errorf("boom"))).Details(), "other errors should carry no reason")
+}
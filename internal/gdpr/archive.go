@@ -0,0 +1,255 @@
+// Package gdpr builds and verifies the signed archives returned for
+// subject-access requests.
+//
+// An archive is a zip file holding the user record, its change history and
+// the audit records of reads of it, one JSON document per line, along with a
+// manifest listing the SHA-256 of every file. The manifest is signed with
+// Ed25519, so the archive can be checked offline with the server's public
+// key.
+package gdpr
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Names of the files in an archive.
+const (
+	UserFile      = "user.json"
+	HistoryFile   = "history.jsonl"
+	AuditFile     = "audit.jsonl"
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.sig"
+)
+
+// ErrInvalidArchive is returned by Verify for archives that are malformed,
+// modified or not signed by the expected key.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// Contents is everything held about one user.
+type Contents struct {
+	UserID uint32
+	// Tenant is the tenant holding the user.
+	Tenant string
+	// User is nil when the user was deleted but its history is still kept.
+	User    *pb.User
+	History []*pb.UserRevision
+	// Audit holds the audit records of reads of the user as stored in the
+	// audit log.
+	Audit []json.RawMessage
+}
+
+// Manifest describes the files of an archive.
+type Manifest struct {
+	UserID    uint32    `json:"userId"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Files     []File    `json:"files"`
+	// PublicKey is the base64 Ed25519 key that signed the archive.
+	PublicKey string `json:"publicKey"`
+}
+
+// File is the manifest entry of one file.
+type File struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Build returns the archive of c created at now and signed with key.
+func Build(c Contents, key ed25519.PrivateKey, now time.Time) ([]byte, error) {
+	files := make(map[string][]byte)
+	var order []string
+	add := func(name string, data []byte) {
+		files[name] = data
+		order = append(order, name)
+	}
+
+	if c.User != nil {
+		data, err := protojson.Marshal(c.User)
+		if err != nil {
+			return nil, err
+		}
+		add(UserFile, append(data, '\n'))
+	}
+	var history bytes.Buffer
+	for _, r := range c.History {
+		data, err := protojson.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		history.Write(append(data, '\n'))
+	}
+	add(HistoryFile, history.Bytes())
+	var records bytes.Buffer
+	for _, r := range c.Audit {
+		records.Write(r)
+		records.WriteByte('\n')
+	}
+	add(AuditFile, records.Bytes())
+
+	manifest := Manifest{
+		UserID:    c.UserID,
+		Tenant:    c.Tenant,
+		CreatedAt: now.UTC(),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	for _, name := range order {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, File{Name: name, Size: len(files[name]), SHA256: hex.EncodeToString(sum[:])})
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	add(ManifestFile, manifestData)
+	add(SignatureFile, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifestData))+"\n"))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range order {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify checks that the manifest of archive is signed by pub and that the
+// archive holds exactly the files it lists, unmodified. With a nil pub the
+// key named in the manifest is used, which only proves the archive was not
+// modified after it was signed, not who signed it.
+func Verify(archive []byte, pub ed25519.PublicKey) (*Manifest, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, f.Name, err)
+		}
+		if _, dup := files[f.Name]; dup {
+			return nil, fmt.Errorf("%w: duplicate file %s", ErrInvalidArchive, f.Name)
+		}
+		files[f.Name] = data
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(files[ManifestFile], &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, ManifestFile, err)
+	}
+	if pub == nil {
+		key, err := base64.StdEncoding.DecodeString(manifest.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: malformed public key in %s", ErrInvalidArchive, ManifestFile)
+		}
+		pub = key
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(files[SignatureFile])))
+	if err != nil || !ed25519.Verify(pub, files[ManifestFile], sig) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidArchive)
+	}
+
+	listed := map[string]bool{ManifestFile: true, SignatureFile: true}
+	for _, f := range manifest.Files {
+		data, found := files[f.Name]
+		if !found {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, f.Name)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != f.SHA256 || len(data) != f.Size {
+			return nil, fmt.Errorf("%w: %s was modified", ErrInvalidArchive, f.Name)
+		}
+		listed[f.Name] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%w: unlisted file %s", ErrInvalidArchive, name)
+		}
+	}
+	return &manifest, nil
+}
+
+// LoadPrivateKey reads a PEM-encoded PKCS #8 Ed25519 private key, as written
+// by `openssl genpkey -algorithm ed25519`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if ed, ok := key.(ed25519.PrivateKey); ok {
+		return ed, nil
+	}
+	return nil, fmt.Errorf("parse %s: not an Ed25519 key", path)
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 public key, as written by
+// `openssl pkey -pubout` or EncodePublicKey.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if ed, ok := key.(ed25519.PublicKey); ok {
+		return ed, nil
+	}
+	return nil, fmt.Errorf("parse %s: not an Ed25519 key", path)
+}
+
+// EncodePublicKey returns pub as a PEM-encoded PKIX public key.
+func EncodePublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("parse %s: no %s PEM block", path, blockType)
+	}
+	return block.Bytes, nil
+}
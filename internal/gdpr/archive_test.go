@@ -0,0 +1,162 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func contents() Contents {
+	user := &pb.User{Id: 4, Fname: "Carol", City: "SF", Version: 2}
+	return Contents{
+		UserID: 4,
+		Tenant: "acme",
+		User:   user,
+		History: []*pb.UserRevision{
+			{Type: pb.UserEvent_CREATED, Actor: "alice", User: &pb.User{Id: 4, Fname: "Carol", City: "LA", Version: 1}},
+			{Type: pb.UserEvent_UPDATED, Actor: "bob", User: user},
+		},
+		Audit: []json.RawMessage{json.RawMessage(`{"seq":1,"subject":"support","userIds":[4]}`)},
+	}
+}
+
+// files returns the contents of the files of archive by name.
+func files(t *testing.T, archive []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		out[f.Name] = string(data)
+	}
+	return out
+}
+
+// rezip returns an archive holding files.
+func rezip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuild(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	archive, err := Build(contents(), key, now)
+	assert.NoError(t, err)
+
+	manifest, err := Verify(archive, pub)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4), manifest.UserID)
+	assert.Equal(t, "acme", manifest.Tenant)
+	assert.Equal(t, now, manifest.CreatedAt)
+
+	got := files(t, archive)
+	assert.Contains(t, got[UserFile], `"fname":"Carol"`)
+	assert.Equal(t, 2, strings.Count(got[HistoryFile], "\n"))
+	assert.Equal(t, `{"seq":1,"subject":"support","userIds":[4]}`+"\n", got[AuditFile])
+}
+
+func TestBuildDeletedUser(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	c := contents()
+	c.User = nil
+
+	archive, err := Build(c, key, time.Now())
+	assert.NoError(t, err)
+	_, err = Verify(archive, pub)
+	assert.NoError(t, err)
+	assert.NotContains(t, files(t, archive), UserFile)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	archive, err := Build(contents(), key, time.Now())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		tamper func(files map[string]string)
+		pub    ed25519.PublicKey
+	}{
+		{name: "should reject a modified file", tamper: func(f map[string]string) { f[UserFile] = strings.Replace(f[UserFile], "Carol", "Eve", 1) }, pub: pub},
+		{name: "should reject a removed file", tamper: func(f map[string]string) { delete(f, AuditFile) }, pub: pub},
+		{name: "should reject an added file", tamper: func(f map[string]string) { f["extra.json"] = "{}" }, pub: pub},
+		{name: "should reject a modified manifest", tamper: func(f map[string]string) {
+			f[ManifestFile] = strings.Replace(f[ManifestFile], `"userId": 4`, `"userId": 5`, 1)
+		}, pub: pub},
+		{name: "should reject another signer", tamper: func(map[string]string) {}, pub: otherPub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := files(t, archive)
+			tt.tamper(f)
+			_, err := Verify(rezip(t, f), tt.pub)
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+		})
+	}
+
+	t.Run("should use the key of the manifest without a public key", func(t *testing.T) {
+		_, err := Verify(archive, nil)
+		assert.NoError(t, err)
+	})
+	t.Run("should reject a file that is not a zip", func(t *testing.T) {
+		_, err := Verify([]byte("not a zip"), pub)
+		assert.ErrorIs(t, err, ErrInvalidArchive)
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	pubPEM, err := EncodePublicKey(pub)
+	assert.NoError(t, err)
+	pubFile := filepath.Join(dir, "pub.pem")
+	os.WriteFile(pubFile, pubPEM, 0o600)
+
+	loadedKey, err := LoadPrivateKey(keyFile)
+	assert.NoError(t, err)
+	assert.True(t, key.Equal(loadedKey))
+	loadedPub, err := LoadPublicKey(pubFile)
+	assert.NoError(t, err)
+	assert.True(t, pub.Equal(loadedPub))
+
+	_, err = LoadPrivateKey(pubFile)
+	assert.Error(t, err, "a public key is not a private key")
+	_, err = LoadPublicKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+// Package grpctest runs a UserService server in memory for tests.
+package grpctest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Dial serves srv with opts on an in-memory listener and returns a client
+// connection to it. The server and the connection are closed when the test
+// ends.
+func Dial(t testing.TB, srv pb.UserServiceServer, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(opts...)
+	pb.RegisterUserServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
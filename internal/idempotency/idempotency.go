@@ -0,0 +1,235 @@
+// Package idempotency replays the result of a write RPC when it is retried
+// with the same idempotency key, so a retry after a lost response does not
+// apply the write twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/internal/tenant"
+	"user-service-module/pkg/userapi"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// KeyHeader is the request metadata entry carrying the idempotency key.
+	KeyHeader = userapi.IdempotencyKeyHeader
+	// ReplayedHeader is set to "true" in the response header when the
+	// response is a replay of an earlier call.
+	ReplayedHeader = userapi.ReplayedHeader
+
+	// DefaultTTL is how long a key and its response are kept.
+	DefaultTTL = 24 * time.Hour
+
+	// maxKeyLength bounds the length of a key.
+	maxKeyLength = 255
+	// maxSweepInterval bounds how often expired keys are dropped.
+	maxSweepInterval = time.Minute
+)
+
+// DefaultMethods are the unary write RPCs honouring idempotency keys.
+var DefaultMethods = []string{
+	pb.UserService_CreateUser_FullMethodName,
+	pb.UserService_UpdateUser_FullMethodName,
+	pb.UserService_DeleteUser_FullMethodName,
+	pb.UserService_EraseUser_FullMethodName,
+	pb.UserService_RegisterAttribute_FullMethodName,
+	pb.UserService_DeleteAttribute_FullMethodName,
+}
+
+// entry is the record of one key. done is closed when the first call with the
+// key finishes; resp is only set if it succeeded.
+type entry struct {
+	tenant      string
+	method      string
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	resp        any
+	expires     time.Time
+	// ID of the user in resp, if any
+	userID uint32
+}
+
+// Store remembers the responses of successful calls by key.
+type Store struct {
+	ttl     time.Duration
+	methods map[string]bool
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewStore returns a Store keeping responses to the given methods for ttl.
+func NewStore(ttl time.Duration, methods []string) *Store {
+	s := &Store{
+		ttl:     ttl,
+		methods: make(map[string]bool, len(methods)),
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+	for _, m := range methods {
+		s.methods[m] = true
+	}
+	return s
+}
+
+// Len returns the number of keys currently held.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// UnaryServerInterceptor runs the first call with a key and replays its
+// response to later calls with the same key and request. A call reusing a key
+// with a different request fails with InvalidArgument. A call arriving while
+// the first is still running waits for it. Failed calls are not recorded, so
+// retrying them runs the handler again.
+func (s *Store) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := keyFromContext(ctx)
+		msg, ok := req.(proto.Message)
+		if key == "" || !ok || !s.methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if len(key) > maxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "%s longer than %d bytes", KeyHeader, maxKeyLength)
+		}
+		fingerprint, err := fingerprintOf(msg)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "fingerprint request: %v", err)
+		}
+
+		key = scope(ctx, key)
+		for {
+			e, first := s.begin(key, tenant.FromContext(ctx), info.FullMethod, fingerprint)
+			if first {
+				resp, err := handler(ctx, req)
+				s.finish(key, e, resp, err)
+				return resp, err
+			}
+			if e.method != info.FullMethod || e.fingerprint != fingerprint {
+				return nil, errors.ToStatus(fmt.Errorf("%w: %s was first used for %s", errors.ErrIdempotencyKeyReused, KeyHeader, e.method))
+			}
+
+			select {
+			case <-e.done:
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			if e.resp != nil {
+				grpc.SetHeader(ctx, metadata.Pairs(ReplayedHeader, "true"))
+				return e.resp, nil
+			}
+			// The first call failed and its key was released: run this one
+		}
+	}
+}
+
+// begin returns the entry for key, creating it when absent. first reports
+// whether the caller created it and so must run the call.
+func (s *Store) begin(key, tenant, method string, fingerprint [sha256.Size]byte) (e *entry, first bool) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+	}
+	if e, found := s.entries[key]; found && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e, false
+	}
+	e = &entry{tenant: tenant, method: method, fingerprint: fingerprint, done: make(chan struct{})}
+	s.entries[key] = e
+	return e, true
+}
+
+// finish records the outcome of the first call with key and wakes the calls
+// waiting on it.
+func (s *Store) finish(key string, e *entry, resp any, err error) {
+	s.mu.Lock()
+	if err != nil || resp == nil {
+		delete(s.entries, key)
+	} else {
+		e.resp = resp
+		e.expires = s.now().Add(s.ttl)
+		if r, ok := resp.(interface{ GetUser() *pb.User }); ok {
+			e.userID = r.GetUser().GetId()
+		}
+	}
+	s.mu.Unlock()
+	close(e.done)
+}
+
+// Forget drops the responses holding the given user of tenant, so they are
+// not replayed once the user is erased, and returns how many it dropped.
+// Later calls with their keys run again.
+func (s *Store) Forget(tenant string, userID uint32) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	forgotten := 0
+	for key, e := range s.entries {
+		if e.resp != nil && e.tenant == tenant && e.userID == userID {
+			delete(s.entries, key)
+			forgotten++
+		}
+	}
+	return forgotten
+}
+
+// sweep drops expired keys. Keys of calls still running have no expiry and
+// are kept. The caller must hold mu.
+func (s *Store) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.nextSweep = now.Add(min(s.ttl, maxSweepInterval))
+}
+
+// keyFromContext returns the idempotency key of an incoming request.
+func keyFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(KeyHeader); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// scope prefixes key with the tenant and the authenticated subject, so callers
+// cannot replay each other's responses by guessing keys, nor the responses of
+// another tenant.
+func scope(ctx context.Context, key string) string {
+	subject := auth.AnonymousSubject
+	if id, ok := auth.FromContext(ctx); ok {
+		subject = id.Subject
+	}
+	return tenant.FromContext(ctx) + "\x00" + subject + "\x00" + key
+}
+
+// fingerprintOf hashes the serialized request to detect a key reused with a
+// different request.
+func fingerprintOf(msg proto.Message) ([sha256.Size]byte, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
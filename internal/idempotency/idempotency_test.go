@@ -0,0 +1,236 @@
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/server"
+	"user-service-module/internal/tenant"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var createInfo = &grpc.UnaryServerInfo{FullMethod: pb.UserService_CreateUser_FullMethodName}
+
+// counter is a handler counting its calls and returning the count as the ID
+// of the created user.
+type counter struct {
+	calls atomic.Uint32
+	err   error
+}
+
+func (c *counter) handle(ctx context.Context, req any) (any, error) {
+	n := c.calls.Add(1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &pb.CreateUserResponse{User: &pb.User{Id: n}}, nil
+}
+
+func withKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(KeyHeader, key))
+}
+
+func create(fname string) *pb.CreateUserRequest {
+	return &pb.CreateUserRequest{User: &pb.User{Fname: fname}}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       [2]context.Context
+		reqs      [2]*pb.CreateUserRequest
+		info      *grpc.UnaryServerInfo
+		wantCalls uint32
+		wantCode  codes.Code
+	}{
+		{
+			name:      "should replay a retry with the same key",
+			ctx:       [2]context.Context{withKey("a"), withKey("a")},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      createInfo,
+			wantCalls: 1,
+		},
+		{
+			name:      "should run calls with different keys",
+			ctx:       [2]context.Context{withKey("a"), withKey("b")},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      createInfo,
+			wantCalls: 2,
+		},
+		{
+			name:      "should run calls without a key",
+			ctx:       [2]context.Context{context.Background(), context.Background()},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      createInfo,
+			wantCalls: 2,
+		},
+		{
+			name:      "should ignore keys on other methods",
+			ctx:       [2]context.Context{withKey("a"), withKey("a")},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      &grpc.UnaryServerInfo{FullMethod: pb.UserService_GetUser_FullMethodName},
+			wantCalls: 2,
+		},
+		{
+			name:      "should reject a key reused with another request",
+			ctx:       [2]context.Context{withKey("a"), withKey("a")},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Dave")},
+			info:      createInfo,
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			name: "should scope keys by subject",
+			ctx: [2]context.Context{
+				auth.NewContext(withKey("a"), &auth.Identity{Subject: "alice"}),
+				auth.NewContext(withKey("a"), &auth.Identity{Subject: "bob"}),
+			},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      createInfo,
+			wantCalls: 2,
+		},
+		{
+			name: "should scope keys by tenant",
+			ctx: [2]context.Context{
+				tenant.NewContext(withKey("a"), "acme"),
+				tenant.NewContext(withKey("a"), "globex"),
+			},
+			reqs:      [2]*pb.CreateUserRequest{create("Carol"), create("Carol")},
+			info:      createInfo,
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intercept := NewStore(time.Hour, DefaultMethods).UnaryServerInterceptor()
+			c := &counter{}
+
+			first, err := intercept(tt.ctx[0], tt.reqs[0], tt.info, c.handle)
+			assert.NoError(t, err)
+			second, err := intercept(tt.ctx[1], tt.reqs[1], tt.info, c.handle)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCalls, c.calls.Load())
+			if err == nil && tt.wantCalls == 1 {
+				assert.Same(t, first, second, "the response should be replayed")
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorFailureNotRecorded(t *testing.T) {
+	store := NewStore(time.Hour, DefaultMethods)
+	intercept := store.UnaryServerInterceptor()
+	c := &counter{err: status.Error(codes.Unavailable, "try again")}
+
+	_, err := intercept(withKey("a"), create("Carol"), createInfo, c.handle)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 0, store.Len())
+
+	c.err = nil
+	resp, err := intercept(withKey("a"), create("Carol"), createInfo, c.handle)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), resp.(*pb.CreateUserResponse).User.Id)
+}
+
+func TestUnaryServerInterceptorExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewStore(time.Minute, DefaultMethods)
+	store.now = func() time.Time { return now }
+	intercept := store.UnaryServerInterceptor()
+	c := &counter{}
+
+	intercept(withKey("a"), create("Carol"), createInfo, c.handle)
+	now = now.Add(59 * time.Second)
+	intercept(withKey("a"), create("Carol"), createInfo, c.handle)
+	assert.Equal(t, uint32(1), c.calls.Load(), "key should be kept until the TTL")
+
+	now = now.Add(time.Second)
+	intercept(withKey("b"), create("Carol"), createInfo, c.handle)
+	assert.Equal(t, 1, store.Len(), "expired keys should be swept")
+	intercept(withKey("a"), create("Dave"), createInfo, c.handle)
+	assert.Equal(t, uint32(3), c.calls.Load(), "expired key should be usable again")
+}
+
+func TestUnaryServerInterceptorConcurrentRetries(t *testing.T) {
+	intercept := NewStore(time.Hour, DefaultMethods).UnaryServerInterceptor()
+	release := make(chan struct{})
+	var calls atomic.Uint32
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls.Add(1)
+		<-release
+		return &pb.CreateUserResponse{User: &pb.User{Id: 4}}, nil
+	}
+
+	var wg sync.WaitGroup
+	ids := make([]uint32, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := intercept(withKey("a"), create("Carol"), createInfo, handler)
+			if assert.NoError(t, err) {
+				ids[i] = resp.(*pb.CreateUserResponse).User.Id
+			}
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, uint32(1), calls.Load())
+	for _, id := range ids {
+		assert.Equal(t, uint32(4), id)
+	}
+}
+
+func TestUnaryServerInterceptorKeyTooLong(t *testing.T) {
+	intercept := NewStore(time.Hour, DefaultMethods).UnaryServerInterceptor()
+	c := &counter{}
+
+	_, err := intercept(withKey(strings.Repeat("k", maxKeyLength+1)), create("Carol"), createInfo, c.handle)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, uint32(0), c.calls.Load())
+}
+
+func TestForget(t *testing.T) {
+	store := NewStore(time.Hour, DefaultMethods)
+	intercept := store.UnaryServerInterceptor()
+	userServer := server.NewUserServer(server.WithResponseCache(func(userID uint32) int {
+		return store.Forget(tenant.Default, userID)
+	}))
+	createUser := func(ctx context.Context, req any) (any, error) {
+		return userServer.CreateUser(ctx, req.(*pb.CreateUserRequest))
+	}
+	req := &pb.CreateUserRequest{User: &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}}
+
+	first, err := intercept(withKey("a"), req, createInfo, createUser)
+	assert.NoError(t, err)
+	id := first.(*pb.CreateUserResponse).User.Id
+	_, err = intercept(tenant.NewContext(withKey("a"), "acme"), req, createInfo, func(ctx context.Context, req any) (any, error) {
+		return &pb.CreateUserResponse{User: &pb.User{Id: id}}, nil
+	})
+	assert.NoError(t, err)
+
+	resp, err := userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: id})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), resp.Receipt.CachedResponsesErased)
+	assert.Equal(t, 1, store.Len(), "the other tenant's response should be kept")
+
+	replay, err := intercept(withKey("a"), req, createInfo, createUser)
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, replay.(*pb.CreateUserResponse).User.Id, "the key should create a new user instead of replaying the erased one")
+}
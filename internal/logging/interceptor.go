@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"user-service-module/pkg/userapi"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestIDKey is the metadata key carrying the request ID in both directions.
+const RequestIDKey = userapi.RequestIDHeader
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned to the current RPC.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDFromError returns the request ID attached to an error returned by
+// the server, if any.
+func RequestIDFromError(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RequestInfo); ok {
+			return info.RequestId
+		}
+	}
+	return ""
+}
+
+// NewRequestID returns a random 128-bit hex request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UnaryServerInterceptor assigns or propagates the request ID, returns it in
+// the response header, attaches it to errors and writes one access log line.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, requestID, reqLogger := startRequest(ctx, logger, info.FullMethod)
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+		err = withRequestID(err, requestID)
+
+		size := 0
+		if msg, ok := resp.(proto.Message); ok && err == nil {
+			size = proto.Size(msg)
+		}
+		logAccess(ctx, reqLogger, start, err, slog.Int("resp_bytes", size))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+// The access line reports the number of messages sent.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID, reqLogger := startRequest(ss.Context(), logger, info.FullMethod)
+		start := time.Now()
+
+		stream := &loggedStream{ServerStream: ss, ctx: ctx}
+		err := withRequestID(handler(srv, stream), requestID)
+
+		logAccess(ctx, reqLogger, start, err, slog.Int("msgs_sent", stream.sent))
+		return err
+	}
+}
+
+// UnaryClientInterceptor sends a fresh request ID with every call that does
+// not already carry one.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(RequestIDKey)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, NewRequestID())
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+type loggedStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent int
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggedStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+func startRequest(ctx context.Context, logger *slog.Logger, method string) (context.Context, string, *slog.Logger) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDKey); len(v) > 0 && v[0] != "" {
+			requestID = v[0]
+		}
+	}
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, requestID))
+
+	reqLogger := logger.With(slog.String("request_id", requestID), slog.String("method", method))
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return NewContext(ctx, reqLogger), requestID, reqLogger
+}
+
+func logAccess(ctx context.Context, logger *slog.Logger, start time.Time, err error, size slog.Attr) {
+	peerAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+
+	st := status.Convert(err)
+	attrs := []slog.Attr{
+		slog.String("peer", peerAddr),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("code", st.Code().String()),
+		size,
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", st.Message()))
+	}
+	logger.LogAttrs(ctx, level, "rpc", attrs...)
+}
+
+// withRequestID attaches the request ID to an error as a RequestInfo detail.
+func withRequestID(err error, requestID string) error {
+	if err == nil {
+		return nil
+	}
+	st, detailErr := status.Convert(err).WithDetails(&errdetails.RequestInfo{RequestId: requestID})
+	if detailErr != nil {
+		return err
+	}
+	return st.Err()
+}
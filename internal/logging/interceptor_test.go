@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func dial(t *testing.T, logger *slog.Logger) pb.UserServiceClient {
+	t.Helper()
+	conn := grpctest.Dial(t, server.NewUserServer(), grpc.UnaryInterceptor(UnaryServerInterceptor(logger)))
+	return pb.NewUserServiceClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelInfo)
+	assert.NoError(t, err)
+	client := dial(t, logger)
+
+	t.Run("should propagate the caller request ID", func(t *testing.T) {
+		buf.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDKey, "req-123")
+		var header metadata.MD
+		_, err := client.GetUser(ctx, &pb.GetUserRequest{Id: 1}, grpc.Header(&header))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"req-123"}, header.Get(RequestIDKey))
+
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "req-123", line["request_id"])
+		assert.Equal(t, pb.UserService_GetUser_FullMethodName, line["method"])
+		assert.Equal(t, "OK", line["code"])
+		assert.NotZero(t, line["resp_bytes"])
+	})
+
+	t.Run("should assign a request ID and attach it to errors", func(t *testing.T) {
+		buf.Reset()
+		var header metadata.MD
+		_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: 999}, grpc.Header(&header))
+		assert.Error(t, err)
+
+		requestID := RequestIDFromError(err)
+		assert.Len(t, requestID, 32)
+		assert.Equal(t, []string{requestID}, header.Get(RequestIDKey))
+
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, requestID, line["request_id"])
+		assert.Equal(t, "WARN", line["level"])
+	})
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo)
+	assert.Error(t, err)
+}
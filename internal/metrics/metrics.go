@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"user-service-module/internal/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics holds the RPC collectors and the registry they are exposed from.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inflight *prometheus.GaugeVec
+	panics   *prometheus.CounterVec
+}
+
+// New returns Metrics registered on a fresh registry together with the Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, by method and status code.",
+		}, []string{"method", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_errors_total",
+			Help: "Total number of RPCs that returned an error, by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Latency of RPCs handled by the server, by method.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"method"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_server_in_flight_requests",
+			Help: "Number of RPCs currently being handled, by method.",
+		}, []string{"method"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_panics_total",
+			Help: "Total number of panics recovered in RPC handlers, by method.",
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.errors, m.latency, m.inflight, m.panics,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are registered on.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Store is the user store whose statistics are exposed, a single
+// *server.UserServer or every tenant of a *server.Tenants.
+type Store interface {
+	Stats() server.Stats
+}
+
+// RegisterStore exposes the statistics of the user store.
+func (m *Metrics) RegisterStore(s Store) {
+	m.registry.MustRegister(newStoreCollector(s))
+}
+
+// RecordPanic counts a panic recovered while handling method.
+func (m *Metrics) RecordPanic(method string) {
+	m.panics.WithLabelValues(method).Inc()
+}
+
+// UnaryServerInterceptor records the outcome and latency of unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		done := m.start(info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the outcome and duration of streaming RPCs.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := m.start(info.FullMethod)
+		err := handler(srv, ss)
+		done(err)
+		return err
+	}
+}
+
+func (m *Metrics) start(method string) func(error) {
+	start := time.Now()
+	inflight := m.inflight.WithLabelValues(method)
+	inflight.Inc()
+
+	return func(err error) {
+		inflight.Dec()
+		m.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+		code := status.Code(err).String()
+		m.requests.WithLabelValues(method, code).Inc()
+		if err != nil {
+			m.errors.WithLabelValues(method, code).Inc()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_GetUser_FullMethodName}
+
+	ok := func(ctx context.Context, req any) (any, error) { return &pb.GetUserResponse{}, nil }
+	notFound := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	}
+
+	interceptor(context.Background(), nil, info, ok)
+	interceptor(context.Background(), nil, info, ok)
+	interceptor(context.Background(), nil, info, notFound)
+
+	method := pb.UserService_GetUser_FullMethodName
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues(method, "OK")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(method, "NotFound")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.errors.WithLabelValues(method, "NotFound")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.inflight.WithLabelValues(method)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.latency))
+}
+
+func TestStoreCollector(t *testing.T) {
+	expected := `
+# HELP user_store_users Number of users held in the store.
+# TYPE user_store_users gauge
+user_store_users 3
+# HELP user_store_index_keys Number of distinct keys in each secondary index.
+# TYPE user_store_index_keys gauge
+user_store_index_keys{index="attribute"} 0
+user_store_index_keys{index="city"} 2
+user_store_index_keys{index="country"} 0
+user_store_index_keys{index="date_of_birth"} 0
+user_store_index_keys{index="email"} 0
+user_store_index_keys{index="lname"} 0
+user_store_index_keys{index="married"} 2
+user_store_index_keys{index="phone"} 3
+`
+	c := newStoreCollector(server.NewUserServer())
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected), "user_store_users", "user_store_index_keys")
+	assert.NoError(t, err)
+}
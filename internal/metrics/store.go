@@ -0,0 +1,45 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	usersDesc = prometheus.NewDesc(
+		"user_store_users", "Number of users held in the store.", nil, nil)
+	indexKeysDesc = prometheus.NewDesc(
+		"user_store_index_keys", "Number of distinct keys in each secondary index.", []string{"index"}, nil)
+	lockWaitDesc = prometheus.NewDesc(
+		"user_store_lock_wait_seconds_total", "Total time spent waiting to acquire the store lock.", nil, nil)
+	lockAcquisitionsDesc = prometheus.NewDesc(
+		"user_store_lock_acquisitions_total", "Total number of times the store lock was acquired.", nil, nil)
+)
+
+// storeCollector reads the user store statistics at scrape time.
+type storeCollector struct {
+	store Store
+}
+
+func newStoreCollector(s Store) *storeCollector {
+	return &storeCollector{store: s}
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersDesc
+	ch <- indexKeysDesc
+	ch <- lockWaitDesc
+	ch <- lockAcquisitionsDesc
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.store.Stats()
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.CityIndexKeys), "city")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.PhoneIndexKeys), "phone")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.MarriedIndexKeys), "married")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.LnameIndexKeys), "lname")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.EmailIndexKeys), "email")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.BirthIndexKeys), "date_of_birth")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.CountryIndexKeys), "country")
+	ch <- prometheus.MustNewConstMetric(indexKeysDesc, prometheus.GaugeValue, float64(stats.AttrIndexKeys), "attribute")
+	ch <- prometheus.MustNewConstMetric(lockWaitDesc, prometheus.CounterValue, stats.LockWait.Seconds())
+	ch <- prometheus.MustNewConstMetric(lockAcquisitionsDesc, prometheus.CounterValue, float64(stats.LockAcquisitions))
+}
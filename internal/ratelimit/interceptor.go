@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/pkg/userapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterKey is the trailer carrying the number of seconds a throttled
+// client should wait before retrying.
+const RetryAfterKey = userapi.RetryAfterTrailer
+
+// Interceptors enforces per-client rate limits and a global cap on in-flight
+// requests. Either limit may be left unset.
+type Interceptors struct {
+	limiter  *Limiter
+	inflight chan struct{}
+}
+
+// NewInterceptors returns interceptors using limiter (nil disables rate
+// limiting) and allowing at most maxInflight concurrent requests (0 disables
+// the cap).
+func NewInterceptors(limiter *Limiter, maxInflight int) *Interceptors {
+	i := &Interceptors{limiter: limiter}
+	if maxInflight > 0 {
+		i.inflight = make(chan struct{}, maxInflight)
+	}
+	return i
+}
+
+// UnaryServerInterceptor rejects calls over the limits with ResourceExhausted.
+func (i *Interceptors) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := i.admit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor charges a stream once when it is opened. The
+// in-flight slot is given back as soon as the stream is admitted, since long
+// lived streams such as WatchUsers would otherwise starve unary calls.
+func (i *Interceptors) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := i.admit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		release()
+		return handler(srv, ss)
+	}
+}
+
+func (i *Interceptors) admit(ctx context.Context, method string) (func(), error) {
+	if i.limiter != nil {
+		if ok, wait := i.limiter.Allow(ClientKey(ctx), method); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			grpc.SetTrailer(ctx, metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds)))
+			return nil, errors.WithReason(status.Newf(codes.ResourceExhausted,
+				"rate limit exceeded for %s, retry after %v", method, wait.Round(time.Millisecond)), userapi.ReasonRateLimited)
+		}
+	}
+
+	if i.inflight == nil {
+		return func() {}, nil
+	}
+	select {
+	case i.inflight <- struct{}{}:
+		return func() { <-i.inflight }, nil
+	default:
+		grpc.SetTrailer(ctx, metadata.Pairs(RetryAfterKey, "1"))
+		return nil, errors.WithReason(status.New(codes.ResourceExhausted, "server overloaded: too many requests in flight"), userapi.ReasonOverloaded)
+	}
+}
+
+// ClientKey identifies the caller for rate limiting: the authenticated
+// subject when known, otherwise the peer IP address.
+func ClientKey(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok && id.Subject != auth.AnonymousSubject {
+		return "subject:" + id.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "peer:" + host
+		}
+		return "peer:" + p.Addr.String()
+	}
+	return "unknown"
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+)
+
+// maxIdleBuckets bounds the number of tracked clients before full buckets are evicted.
+const maxIdleBuckets = 10000
+
+// DefaultCosts charges more tokens for RPCs that scan the whole store, write
+// to it or hold a stream open.
+var DefaultCosts = map[string]float64{
+	pb.UserService_GetUser_FullMethodName:              1,
+	pb.UserService_ListUsers_FullMethodName:            2,
+	pb.UserService_SearchUsers_FullMethodName:          5,
+	pb.UserService_CreateUser_FullMethodName:           2,
+	pb.UserService_UpdateUser_FullMethodName:           2,
+	pb.UserService_DeleteUser_FullMethodName:           2,
+	pb.UserService_WatchUsers_FullMethodName:           5,
+	pb.UserService_ImportUsers_FullMethodName:          10,
+	pb.UserService_ExportUsers_FullMethodName:          10,
+	pb.UserService_BatchCreateUsers_FullMethodName:     10,
+	pb.UserService_GetUserHistory_FullMethodName:       2,
+	pb.UserService_ExportUserData_FullMethodName:       10,
+	pb.UserService_EraseUser_FullMethodName:            2,
+	pb.UserService_RegisterAttribute_FullMethodName:    2,
+	pb.UserService_ListAttributes_FullMethodName:       1,
+	pb.UserService_DeleteAttribute_FullMethodName:      2,
+	pb.UserService_FindUniqueViolations_FullMethodName: 10,
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token-bucket rate limiter keyed by client.
+type Limiter struct {
+	rate  float64
+	burst float64
+	costs map[string]float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewLimiter returns a Limiter refilling rate tokens per second up to burst
+// tokens per client. Methods missing from costs cost one token.
+func NewLimiter(rate, burst float64, costs map[string]float64) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		costs:   costs,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Cost returns the number of tokens charged for a call to method.
+func (l *Limiter) Cost(method string) float64 {
+	if cost, found := l.costs[method]; found {
+		return math.Min(cost, l.burst)
+	}
+	return math.Min(1, l.burst)
+}
+
+// Allow charges the cost of method to key. When the bucket does not hold
+// enough tokens it returns false and how long the client should wait.
+func (l *Limiter) Allow(key, method string) (bool, time.Duration) {
+	cost := l.Cost(method)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.evict(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= cost {
+		b.tokens -= cost
+		return true, 0
+	}
+	wait := (cost - b.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// evict drops buckets that have refilled completely, since they carry no state.
+func (l *Limiter) evict(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(1, 5, DefaultCosts)
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.Allow("a", pb.UserService_SearchUsers_FullMethodName)
+	assert.True(t, ok, "full bucket should pay for a search")
+
+	ok, wait := l.Allow("a", pb.UserService_GetUser_FullMethodName)
+	assert.False(t, ok, "empty bucket should reject")
+	assert.Equal(t, time.Second, wait)
+
+	ok, _ = l.Allow("b", pb.UserService_GetUser_FullMethodName)
+	assert.True(t, ok, "clients must not share buckets")
+
+	now = now.Add(2 * time.Second)
+	ok, _ = l.Allow("a", pb.UserService_ListUsers_FullMethodName)
+	assert.True(t, ok, "bucket should refill over time")
+}
+
+func TestLimiterCostClampedToBurst(t *testing.T) {
+	l := NewLimiter(1, 2, DefaultCosts)
+	assert.Equal(t, float64(2), l.Cost(pb.UserService_SearchUsers_FullMethodName))
+	assert.Equal(t, float64(1), l.Cost("/unknown/Method"))
+}
+
+func dial(t *testing.T, i *Interceptors, stream ...grpc.StreamServerInterceptor) pb.UserServiceClient {
+	t.Helper()
+	stream = append([]grpc.StreamServerInterceptor{i.StreamServerInterceptor()}, stream...)
+	conn := grpctest.Dial(t, server.NewUserServer(), grpc.UnaryInterceptor(i.UnaryServerInterceptor()), grpc.ChainStreamInterceptor(stream...))
+	return pb.NewUserServiceClient(conn)
+}
+
+func TestUnaryServerInterceptorRateLimit(t *testing.T) {
+	client := dial(t, NewInterceptors(NewLimiter(0.5, 1, DefaultCosts), 0))
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err)
+
+	var trailer metadata.MD
+	_, err = client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1}, grpc.Trailer(&trailer))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"2"}, trailer.Get(RetryAfterKey))
+}
+
+func TestUnaryServerInterceptorInflight(t *testing.T) {
+	i := NewInterceptors(nil, 1)
+	i.inflight <- struct{}{}
+	client := dial(t, i)
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	<-i.inflight
+	_, err = client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err)
+}
+
+func TestStreamServerInterceptorInflight(t *testing.T) {
+	const watchers = 3
+	opened := make(chan struct{}, watchers)
+	client := dial(t, NewInterceptors(nil, 1), func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		opened <- struct{}{}
+		return handler(srv, ss)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for n := 0; n < watchers; n++ {
+		_, err := client.WatchUsers(ctx, &pb.WatchUsersRequest{})
+		assert.NoError(t, err)
+		select {
+		case <-opened:
+		case <-time.After(time.Second):
+			t.Fatalf("watcher %d was not admitted", n+1)
+		}
+	}
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err, "open watchers should not hold in-flight slots")
+}
@@ -0,0 +1,96 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"user-service-module/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recoverer turns handler panics into Internal errors instead of letting
+// them crash the process.
+type Recoverer struct {
+	crashDir string
+	onPanic  func(method string)
+}
+
+// New returns a Recoverer writing a crash report for every panic to crashDir
+// (skipped when empty) and calling onPanic, when set, with the failing method.
+func New(crashDir string, onPanic func(method string)) *Recoverer {
+	return &Recoverer{crashDir: crashDir, onPanic: onPanic}
+}
+
+// UnaryServerInterceptor recovers panics raised by unary handlers.
+func (r *Recoverer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = r.handle(ctx, info.FullMethod, p, debug.Stack())
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor recovers panics raised by stream handlers.
+func (r *Recoverer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = r.handle(ss.Context(), info.FullMethod, p, debug.Stack())
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func (r *Recoverer) handle(ctx context.Context, method string, p any, stack []byte) error {
+	logger := logging.FromContext(ctx)
+	logger.Error("recovered from panic", "method", method, "panic", fmt.Sprint(p), "stack", string(stack))
+
+	if r.onPanic != nil {
+		r.onPanic(method)
+	}
+	if r.crashDir != "" {
+		path, err := r.writeReport(logging.RequestIDFromContext(ctx), method, p, stack)
+		if err != nil {
+			logger.Error("failed to write crash report", "error", err)
+		} else {
+			logger.Error("wrote crash report", "path", path)
+		}
+	}
+	return status.Errorf(codes.Internal, "internal error while handling %s", method)
+}
+
+func (r *Recoverer) writeReport(requestID, method string, p any, stack []byte) (string, error) {
+	if err := os.MkdirAll(r.crashDir, 0o755); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("crash-%s-%s.txt", now.Format("20060102T150405.000000000"), sanitize(requestID))
+	path := filepath.Join(r.crashDir, name)
+
+	report := fmt.Sprintf("time: %s\nmethod: %s\nrequest_id: %s\npanic: %v\n\n%s",
+		now.Format(time.RFC3339Nano), method, requestID, p, stack)
+	return path, os.WriteFile(path, []byte(report), 0o644)
+}
+
+// sanitize keeps caller-supplied request IDs from escaping the crash directory.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
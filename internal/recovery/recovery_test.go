@@ -0,0 +1,52 @@
+package recovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	crashDir := t.TempDir()
+	var panicked []string
+	interceptor := New(crashDir, func(method string) { panicked = append(panicked, method) }).UnaryServerInterceptor()
+
+	userServer := server.NewUserServer()
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_SearchUsers_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return userServer.SearchUsers(ctx, req.(*pb.SearchUsersRequest))
+	}
+
+	t.Run("should convert a nil request panic into Internal", func(t *testing.T) {
+		_, err := interceptor(context.Background(), (*pb.SearchUsersRequest)(nil), info, handler)
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, []string{pb.UserService_SearchUsers_FullMethodName}, panicked)
+
+		reports, _ := filepath.Glob(filepath.Join(crashDir, "crash-*.txt"))
+		if assert.Len(t, reports, 1) {
+			report, _ := os.ReadFile(reports[0])
+			assert.Contains(t, string(report), "method: "+pb.UserService_SearchUsers_FullMethodName)
+			assert.Contains(t, string(report), "nil pointer dereference")
+		}
+	})
+
+	t.Run("should pass through normal responses", func(t *testing.T) {
+		resp, err := interceptor(context.Background(), &pb.SearchUsersRequest{City: "LA"}, info, handler)
+		assert.NoError(t, err)
+		assert.Len(t, resp.(*pb.SearchUsersResponse).Users, 2)
+	})
+}
+
+func TestSanitize(t *testing.T) {
+	assert.Equal(t, "______etc_passwd", sanitize("../../etc/passwd"))
+	assert.Equal(t, "abc-123_X", sanitize("abc-123_X"))
+}
@@ -0,0 +1,191 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// visibleSuffix is the number of trailing characters left unmasked in string fields.
+const visibleSuffix = 4
+
+var (
+	userName        = (&pb.User{}).ProtoReflect().Descriptor().FullName()
+	fieldChangeName = (&pb.FieldChange{}).ProtoReflect().Descriptor().FullName()
+	violationName   = (&pb.UniqueViolation{}).ProtoReflect().Descriptor().FullName()
+)
+
+// unmaskable lists the methods whose responses cannot be redacted, such as
+// signed archives whose signature would no longer match. Callers without
+// access are refused instead.
+var unmaskable = map[string]bool{
+	pb.UserService_ExportUserData_FullMethodName: true,
+}
+
+// Redactor masks sensitive User fields in responses for callers that are not
+// allowed to see them.
+type Redactor struct {
+	fields  []protoreflect.FieldDescriptor
+	allowed func(ctx context.Context) bool
+}
+
+// NewRedactor returns a Redactor masking the named User fields unless allowed
+// reports true for the request context.
+func NewRedactor(fields []string, allowed func(ctx context.Context) bool) (*Redactor, error) {
+	userFields := (&pb.User{}).ProtoReflect().Descriptor().Fields()
+
+	r := &Redactor{allowed: allowed}
+	for _, name := range fields {
+		fd := userFields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("redact: unknown user field %q", name)
+		}
+		r.fields = append(r.fields, fd)
+	}
+	return r, nil
+}
+
+// Mask returns a masked copy of s, keeping only its last few characters.
+func Mask(s string) string {
+	if len(s) <= visibleSuffix {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-visibleSuffix) + s[len(s)-visibleSuffix:]
+}
+
+// Apply returns msg with every User it contains redacted, along with the
+// values of every FieldChange and UniqueViolation of a redacted field. The original message is
+// never modified since handlers may return stored users directly.
+func (r *Redactor) Apply(msg proto.Message) proto.Message {
+	if len(r.fields) == 0 || !containsUser(msg.ProtoReflect().Descriptor(), map[protoreflect.FullName]bool{}) {
+		return msg
+	}
+	clone := proto.Clone(msg)
+	r.redact(clone.ProtoReflect())
+	return clone
+}
+
+func (r *Redactor) redact(m protoreflect.Message) {
+	switch m.Descriptor().FullName() {
+	case userName:
+		r.redactUser(m)
+		return
+	case fieldChangeName:
+		r.redactChange(m.Interface().(*pb.FieldChange))
+		return
+	case violationName:
+		r.redactViolation(m.Interface().(*pb.UniqueViolation))
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil || fd.IsMap():
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				r.redact(list.Get(i).Message())
+			}
+		default:
+			r.redact(v.Message())
+		}
+		return true
+	})
+}
+
+func (r *Redactor) redactUser(m protoreflect.Message) {
+	for _, fd := range r.fields {
+		if !m.Has(fd) {
+			continue
+		}
+		if fd.Kind() == protoreflect.StringKind && !fd.IsList() {
+			m.Set(fd, protoreflect.ValueOfString(Mask(m.Get(fd).String())))
+		} else {
+			m.Clear(fd)
+		}
+	}
+}
+
+func (r *Redactor) redactChange(c *pb.FieldChange) {
+	for _, fd := range r.fields {
+		if string(fd.Name()) != c.Field {
+			continue
+		}
+		if fd.Kind() == protoreflect.StringKind && !fd.IsList() {
+			c.Old, c.New = Mask(c.Old), Mask(c.New)
+		} else {
+			c.Old, c.New = "", ""
+		}
+	}
+}
+
+func (r *Redactor) redactViolation(v *pb.UniqueViolation) {
+	for _, fd := range r.fields {
+		if string(fd.Name()) == v.Field {
+			v.Value = Mask(v.Value)
+		}
+	}
+}
+
+// containsUser reports whether messages described by md can hold a User, a
+// FieldChange or a UniqueViolation.
+func containsUser(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) bool {
+	if md.FullName() == userName || md.FullName() == fieldChangeName || md.FullName() == violationName {
+		return true
+	}
+	if seen[md.FullName()] {
+		return false
+	}
+	seen[md.FullName()] = true
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if sub := fields.Get(i).Message(); sub != nil && containsUser(sub, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor redacts unary responses for callers without access,
+// and refuses them the methods whose responses cannot be redacted.
+func (r *Redactor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if unmaskable[info.FullMethod] && len(r.fields) > 0 && !r.allowed(ctx) {
+			return nil, errors.Errorf("%w: %s returns unredacted fields the caller may not read", errors.ErrPermissionDenied, info.FullMethod)
+		}
+		resp, err := handler(ctx, req)
+		if msg, ok := resp.(proto.Message); ok && err == nil && !r.allowed(ctx) {
+			return r.Apply(msg), nil
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor redacts every message sent on a server stream.
+func (r *Redactor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if r.allowed(ss.Context()) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &redactedStream{ServerStream: ss, redactor: r})
+	}
+}
+
+type redactedStream struct {
+	grpc.ServerStream
+	redactor *Redactor
+}
+
+func (s *redactedStream) SendMsg(m any) error {
+	if msg, ok := m.(proto.Message); ok {
+		m = s.redactor.Apply(msg)
+	}
+	return s.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,139 @@
+package redact
+
+import (
+	"context"
+	"testing"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type allowKey struct{}
+
+func allowed(ctx context.Context) bool {
+	ok, _ := ctx.Value(allowKey{}).(bool)
+	return ok
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"9876543210", "******3210"},
+		{"1234", "****"},
+		{"12", "**"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Mask(tt.in); got != tt.expected {
+			t.Errorf("Mask(%q) = %q; want %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	r, err := NewRedactor([]string{"phone", "height"}, allowed)
+	assert.NoError(t, err)
+
+	stored := &pb.User{Id: 2, Fname: "Bob", City: "NY", Phone: "9876543210", Height: 6.1}
+	resp := &pb.ListUsersResponse{StatusCode: 200, Users: []*pb.User{stored}}
+
+	got := r.Apply(resp).(*pb.ListUsersResponse)
+	assert.Equal(t, "******3210", got.Users[0].Phone)
+	assert.Equal(t, float32(0), got.Users[0].Height)
+	assert.Equal(t, "Bob", got.Users[0].Fname)
+	assert.Equal(t, "9876543210", stored.Phone, "stored user must not be modified")
+	assert.Equal(t, float32(6.1), stored.Height, "stored user must not be modified")
+}
+
+func TestApplyHistory(t *testing.T) {
+	r, err := NewRedactor([]string{"phone", "height"}, allowed)
+	assert.NoError(t, err)
+
+	resp := &pb.GetUserHistoryResponse{Revisions: []*pb.UserRevision{{
+		User: &pb.User{Id: 2, Phone: "9876543211"},
+		Changes: []*pb.FieldChange{
+			{Field: "phone", Old: "9876543210", New: "9876543211"},
+			{Field: "height", Old: "6.1", New: "6"},
+			{Field: "city", Old: "NY", New: "Boston"},
+		},
+	}}}
+
+	got := r.Apply(resp).(*pb.GetUserHistoryResponse).Revisions[0]
+	assert.Equal(t, "******3211", got.User.Phone)
+	assert.Equal(t, "******3210", got.Changes[0].Old)
+	assert.Equal(t, "******3211", got.Changes[0].New)
+	assert.Equal(t, "", got.Changes[1].Old+got.Changes[1].New)
+	assert.Equal(t, "Boston", got.Changes[2].New)
+	assert.Equal(t, "9876543210", resp.Revisions[0].Changes[0].Old, "stored history must not be modified")
+}
+
+func TestApplyUniqueViolations(t *testing.T) {
+	r, err := NewRedactor([]string{"phone"}, allowed)
+	assert.NoError(t, err)
+
+	resp := &pb.FindUniqueViolationsResponse{Violations: []*pb.UniqueViolation{
+		{Field: "phone", Value: "9876543210", Ids: []uint32{2, 4}},
+		{Field: "email", Value: "bob@example.com", Ids: []uint32{2, 5}},
+	}}
+
+	got := r.Apply(resp).(*pb.FindUniqueViolationsResponse).Violations
+	assert.Equal(t, "******3210", got[0].Value)
+	assert.Equal(t, []uint32{2, 4}, got[0].Ids)
+	assert.Equal(t, "bob@example.com", got[1].Value)
+	assert.Equal(t, "9876543210", resp.Violations[0].Value, "the response must not be modified")
+}
+
+func TestNewRedactorRejectsUnknownField(t *testing.T) {
+	_, err := NewRedactor([]string{"ssn"}, allowed)
+	assert.Error(t, err)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	r, err := NewRedactor([]string{"phone"}, allowed)
+	assert.NoError(t, err)
+	interceptor := r.UnaryServerInterceptor()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return &pb.GetUserResponse{StatusCode: 200, User: &pb.User{Id: 1, Phone: "9827329211"}}, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_GetUser_FullMethodName}
+
+	resp, err := interceptor(context.Background(), &pb.GetUserRequest{Id: 1}, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "******9211", resp.(*pb.GetUserResponse).User.Phone)
+
+	ctx := context.WithValue(context.Background(), allowKey{}, true)
+	resp, err = interceptor(ctx, &pb.GetUserRequest{Id: 1}, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "9827329211", resp.(*pb.GetUserResponse).User.Phone)
+}
+
+func TestUnaryServerInterceptorRefusesUnmaskable(t *testing.T) {
+	r, err := NewRedactor([]string{"phone"}, func(context.Context) bool { return false })
+	assert.NoError(t, err)
+	interceptor := r.UnaryServerInterceptor()
+
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return &pb.ExportUserDataResponse{}, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_ExportUserData_FullMethodName}
+
+	_, err = interceptor(context.Background(), &pb.ExportUserDataRequest{Id: 1}, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "archives should be refused when no policy grants access")
+	assert.False(t, called)
+
+	r, err = NewRedactor(nil, func(context.Context) bool { return false })
+	assert.NoError(t, err)
+	_, err = r.UnaryServerInterceptor()(context.Background(), &pb.ExportUserDataRequest{Id: 1}, info, handler)
+	assert.NoError(t, err, "archives should be served when redaction is turned off")
+	assert.True(t, called)
+}
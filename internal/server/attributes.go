@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RegisterAttribute adds a custom attribute users may carry, or replaces the
+// definition of a registered one. A replacement is refused while any stored
+// value is invalid under it, and changing whether the attribute is indexed
+// rebuilds its index.
+func (s *UserServer) RegisterAttribute(ctx context.Context, req *pb.RegisterAttributeRequest) (*pb.RegisterAttributeResponse, error) {
+	_, validation := tracer.Start(ctx, "validate")
+	err := utils.ValidateAttributeDefinition(req.Definition)
+	validation.End()
+	if err != nil {
+		return &pb.RegisterAttributeResponse{
+			StatusCode: http.StatusBadRequest,
+		}, err
+	}
+
+	_, write := tracer.Start(ctx, "store.register_attribute")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	def := proto.Clone(req.Definition).(*pb.AttributeDefinition)
+	var invalidIDs []uint32
+	for id, user := range s.users {
+		if value, set := user.Attributes[def.Key]; set && !utils.IsAttributeValueValid(def, value) {
+			invalidIDs = append(invalidIDs, id)
+		}
+	}
+	if len(invalidIDs) > 0 {
+		sort.Slice(invalidIDs, func(i, j int) bool { return invalidIDs[i] < invalidIDs[j] })
+		return &pb.RegisterAttributeResponse{
+			StatusCode: http.StatusConflict,
+		}, fmt.Errorf("%w: users %v have values of %s that the new definition rejects", errors.ErrAttributeInUse, invalidIDs, def.Key)
+	}
+
+	_, replaced := s.attributes[def.Key]
+	s.attributes[def.Key] = def
+	delete(s.byAttribute, def.Key)
+	if def.Indexed {
+		index := make(map[string]map[uint32]struct{})
+		for id, user := range s.users {
+			if value, set := user.Attributes[def.Key]; set {
+				addToIndex(index, value, id)
+			}
+		}
+		s.byAttribute[def.Key] = index
+	}
+
+	statusCode := http.StatusCreated
+	if replaced {
+		statusCode = http.StatusOK
+	}
+	return &pb.RegisterAttributeResponse{
+		StatusCode: uint32(statusCode),
+		Definition: def,
+	}, nil
+}
+
+// ListAttributes returns every registered attribute ordered by key.
+func (s *UserServer) ListAttributes(ctx context.Context, req *pb.ListAttributesRequest) (*pb.ListAttributesResponse, error) {
+	_, lookup := tracer.Start(ctx, "store.list_attributes")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	defs := make([]*pb.AttributeDefinition, 0, len(s.attributes))
+	for _, def := range s.attributes {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Key < defs[j].Key })
+	return &pb.ListAttributesResponse{
+		StatusCode:  http.StatusOK,
+		Definitions: defs,
+	}, nil
+}
+
+// DeleteAttribute unregisters an attribute no user has a value for.
+func (s *UserServer) DeleteAttribute(ctx context.Context, req *pb.DeleteAttributeRequest) (*pb.DeleteAttributeResponse, error) {
+	_, write := tracer.Start(ctx, "store.delete_attribute")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.attributes[req.Key]; !found {
+		return &pb.DeleteAttributeResponse{
+			StatusCode: http.StatusNotFound,
+		}, fmt.Errorf("%w: %s", errors.ErrAttributeNotFound, req.Key)
+	}
+	inUse := 0
+	for _, user := range s.users {
+		if _, set := user.Attributes[req.Key]; set {
+			inUse++
+		}
+	}
+	if inUse > 0 {
+		return &pb.DeleteAttributeResponse{
+			StatusCode: http.StatusConflict,
+		}, fmt.Errorf("%w: %d users have a value of %s", errors.ErrAttributeInUse, inUse, req.Key)
+	}
+
+	delete(s.attributes, req.Key)
+	delete(s.byAttribute, req.Key)
+	return &pb.DeleteAttributeResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// checkAttributes reports the attributes of user that are not registered or
+// whose values are invalid. The caller must hold mu.
+func (s *UserServer) checkAttributes(user *pb.User) error {
+	return utils.ValidateAttributes(user.Attributes, func(key string) *pb.AttributeDefinition {
+		return s.attributes[key]
+	})
+}
+
+// checkSearchAttributes reports the attributes of req that cannot be
+// searched, because they are not indexed or their values are invalid. The
+// caller must hold mu.
+func (s *UserServer) checkSearchAttributes(req *pb.SearchUsersRequest) error {
+	return utils.ValidateAttributes(req.Attributes, func(key string) *pb.AttributeDefinition {
+		if def := s.attributes[key]; def != nil && def.Indexed {
+			return def
+		}
+		return nil
+	})
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+// newAttributeServer returns a server with a "team" enum and a "level" int
+// attribute registered, and user 1 in team core at level 3.
+func newAttributeServer(t *testing.T) *UserServer {
+	t.Helper()
+	userServer := NewUserServer()
+	for _, def := range []*pb.AttributeDefinition{
+		{Key: "team", Type: pb.AttributeDefinition_ENUM, Values: []string{"core", "growth"}, Indexed: true},
+		{Key: "level", Type: pb.AttributeDefinition_INT, Min: int64Ptr(1), Max: int64Ptr(9)},
+	} {
+		resp, err := userServer.RegisterAttribute(context.Background(), &pb.RegisterAttributeRequest{Definition: def})
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(201), resp.StatusCode)
+	}
+	_, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 1, Attributes: map[string]string{"team": "core", "level": "3"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes"}},
+	})
+	assert.NoError(t, err)
+	return userServer
+}
+
+func TestRegisterAttribute(t *testing.T) {
+	tests := []struct {
+		name         string
+		def          *pb.AttributeDefinition
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{
+			name:         "should add a new attribute",
+			def:          &pb.AttributeDefinition{Key: "vip", Type: pb.AttributeDefinition_BOOL},
+			expectedCode: 201,
+		},
+		{
+			name:         "should replace a definition stored values satisfy",
+			def:          &pb.AttributeDefinition{Key: "level", Type: pb.AttributeDefinition_INT, Max: int64Ptr(5)},
+			expectedCode: 200,
+		},
+		{
+			name:         "should return error for a definition stored values break",
+			def:          &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_ENUM, Values: []string{"growth"}},
+			expectedCode: 409,
+			expectedErr:  errors.ErrAttributeInUse,
+		},
+		{
+			name:         "should return error for an invalid definition",
+			def:          &pb.AttributeDefinition{Key: "Team", Type: pb.AttributeDefinition_STRING},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userServer := newAttributeServer(t)
+			resp, err := userServer.RegisterAttribute(context.Background(), &pb.RegisterAttributeRequest{Definition: tt.def})
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			list, err := userServer.ListAttributes(context.Background(), &pb.ListAttributesRequest{})
+			assert.NoError(t, err)
+			assert.Contains(t, list.Definitions, resp.Definition)
+		})
+	}
+}
+
+func TestListAttributes(t *testing.T) {
+	userServer := newAttributeServer(t)
+	resp, err := userServer.ListAttributes(context.Background(), &pb.ListAttributesRequest{})
+	assert.NoError(t, err)
+	var keys []string
+	for _, def := range resp.Definitions {
+		keys = append(keys, def.Key)
+	}
+	assert.Equal(t, []string{"level", "team"}, keys)
+}
+
+func TestDeleteAttribute(t *testing.T) {
+	userServer := newAttributeServer(t)
+
+	resp, err := userServer.DeleteAttribute(context.Background(), &pb.DeleteAttributeRequest{Key: "team"})
+	assert.ErrorIs(t, err, errors.ErrAttributeInUse)
+	assert.Equal(t, uint32(409), resp.StatusCode)
+
+	resp, err = userServer.DeleteAttribute(context.Background(), &pb.DeleteAttributeRequest{Key: "vip"})
+	assert.ErrorIs(t, err, errors.ErrAttributeNotFound)
+	assert.Equal(t, uint32(404), resp.StatusCode)
+
+	_, err = userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 1},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes.team"}},
+	})
+	assert.NoError(t, err)
+	resp, err = userServer.DeleteAttribute(context.Background(), &pb.DeleteAttributeRequest{Key: "team"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(200), resp.StatusCode)
+	assert.Equal(t, 0, userServer.Stats().AttrIndexKeys, "the index of a deleted attribute should be dropped")
+}
+
+func TestUserAttributes(t *testing.T) {
+	valid := func(attributes map[string]string) *pb.User {
+		return &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4, Attributes: attributes}
+	}
+
+	t.Run("should create users with valid attributes", func(t *testing.T) {
+		userServer := newAttributeServer(t)
+		resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid(map[string]string{"team": "growth"})})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "growth"}, resp.User.Attributes)
+	})
+
+	t.Run("should reject unregistered and invalid attributes", func(t *testing.T) {
+		userServer := newAttributeServer(t)
+		resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid(map[string]string{"team": "sales", "level": "3", "vip": "true"})})
+		assert.ErrorIs(t, err, errors.ErrInvalidFields)
+		assert.EqualError(t, err, "error: invalid field(s): attributes.team, attributes.vip")
+		assert.Equal(t, uint32(400), resp.StatusCode)
+	})
+
+	t.Run("should update one attribute by mask path", func(t *testing.T) {
+		userServer := newAttributeServer(t)
+		resp, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+			User:       &pb.User{Id: 1, Attributes: map[string]string{"level": "4", "team": "ignored"}},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes.level"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "core", "level": "4"}, resp.User.Attributes)
+
+		_, err = userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+			User:       &pb.User{Id: 1, Attributes: map[string]string{"level": "10"}},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes.level"}},
+		})
+		assert.ErrorIs(t, err, errors.ErrInvalidFields)
+	})
+
+	t.Run("should report invalid attributes per user in batches", func(t *testing.T) {
+		userServer := newAttributeServer(t)
+		stream := &batchStream{reqs: []*pb.BatchCreateUsersRequest{{Users: []*pb.User{
+			valid(map[string]string{"team": "sales"}),
+			valid(map[string]string{"team": "core"}),
+		}}}}
+		assert.NoError(t, userServer.BatchCreateUsers(stream))
+		results := stream.resps[0].Results
+		assert.Contains(t, results[0].GetError(), "attributes.team")
+		assert.Equal(t, uint32(4), results[1].GetId())
+	})
+
+	t.Run("should record attribute changes in history", func(t *testing.T) {
+		userServer := newAttributeServer(t)
+		resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 1})
+		assert.NoError(t, err)
+		changes := resp.Revisions[len(resp.Revisions)-1].Changes
+		assert.Equal(t, []*pb.FieldChange{{Field: "attributes", New: "level=3, team=core"}}, changes)
+	})
+}
+
+func TestSearchUsersByAttributes(t *testing.T) {
+	userServer := newAttributeServer(t)
+	_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: &pb.User{
+		Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4, Attributes: map[string]string{"team": "growth"},
+	}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		attributes   map[string]string
+		expected     []uint32
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{
+			name:         "should find users by an indexed attribute",
+			attributes:   map[string]string{"team": "core"},
+			expected:     []uint32{1},
+			expectedCode: 200,
+		},
+		{
+			name:         "should return error for an attribute that is not indexed",
+			attributes:   map[string]string{"level": "3"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should return error for an invalid value",
+			attributes:   map[string]string{"team": "sales"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{Attributes: tt.attributes})
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			var ids []uint32
+			for _, u := range resp.Users {
+				ids = append(ids, u.Id)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+
+	t.Run("should index an attribute when it becomes indexed", func(t *testing.T) {
+		def := &pb.AttributeDefinition{Key: "level", Type: pb.AttributeDefinition_INT, Indexed: true}
+		_, err := userServer.RegisterAttribute(context.Background(), &pb.RegisterAttributeRequest{Definition: def})
+		assert.NoError(t, err)
+		resp, err := userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{Attributes: map[string]string{"level": "3"}})
+		assert.NoError(t, err)
+		if assert.Len(t, resp.Users, 1) {
+			assert.Equal(t, uint32(1), resp.Users[0].Id)
+		}
+	})
+}
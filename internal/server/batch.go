@@ -0,0 +1,112 @@
+package server
+
+import (
+	"io"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// batchChunk is the most users created under one hold of the store lock, so
+// readers wait for at most one chunk during a large batch.
+const batchChunk = 256
+
+// BatchCreateUsers creates the users of every request and answers each
+// request with one result per user. Invalid users are reported without
+// aborting the batch.
+func (s *UserServer) BatchCreateUsers(stream pb.UserService_BatchCreateUsersServer) error {
+	span := trace.SpanFromContext(stream.Context())
+	var index uint32
+	created := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			span.SetAttributes(resultCountAttribute(created))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		results := make([]*pb.BatchCreateResult, len(req.Users))
+		valid := make([]*pb.User, 0, len(req.Users))
+		validIdx := make([]int, 0, len(req.Users))
+		_, validation := tracer.Start(stream.Context(), "validate")
+		for i, user := range req.Users {
+			results[i] = &pb.BatchCreateResult{Index: index + uint32(i)}
+			if err := utils.ValidateUser(user); err != nil {
+				results[i].Outcome = &pb.BatchCreateResult_Error{Error: err.Error()}
+				results[i].Reason = errors.Reason(err)
+				continue
+			}
+			valid = append(valid, user)
+			validIdx = append(validIdx, i)
+		}
+		validation.End()
+
+		_, write := tracer.Start(stream.Context(), "store.batch_create")
+		for start := 0; start < len(valid); start += batchChunk {
+			end := min(start+batchChunk, len(valid))
+			ids, errs := s.createChunk(actorOf(stream.Context()), valid[start:end])
+			for i := range valid[start:end] {
+				result := results[validIdx[start+i]]
+				if errs[i] != nil {
+					result.Outcome = &pb.BatchCreateResult_Error{Error: errs[i].Error()}
+					result.Reason = errors.Reason(errs[i])
+				} else {
+					result.Outcome = &pb.BatchCreateResult_Id{Id: ids[i]}
+					created++
+				}
+			}
+		}
+		write.End()
+
+		index += uint32(len(req.Users))
+		if err := stream.Send(&pb.BatchCreateUsersResponse{Results: results}); err != nil {
+			return err
+		}
+	}
+}
+
+// createChunk stores already validated users under one hold of the lock and
+// returns the new ID of each, or the error, such as an invalid attribute, a
+// taken unique value or the quota running out, that kept it from being
+// created.
+func (s *UserServer) createChunk(actor string, users []*pb.User) ([]uint32, []error) {
+	s.lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint32, len(users))
+	errs := make([]error, len(users))
+	for i, u := range users {
+		if err := s.checkAttributes(u); err != nil {
+			errs[i] = err
+			continue
+		}
+		if err := s.checkUnique(u, nil); err != nil {
+			errs[i] = err
+			continue
+		}
+		if err := s.checkQuota(1); err != nil {
+			errs[i] = err
+			continue
+		}
+		id, err := s.allocateID()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		user := proto.Clone(u).(*pb.User)
+		user.Id = id
+		user.Version = 1
+		s.users[user.Id] = user
+		s.index(user)
+		s.changed(actor, pb.UserEvent_CREATED, nil, user)
+		ids[i] = user.Id
+	}
+	return ids, errs
+}
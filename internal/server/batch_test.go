@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type batchStream struct {
+	grpc.ServerStream
+	reqs  []*pb.BatchCreateUsersRequest
+	resps []*pb.BatchCreateUsersResponse
+}
+
+func (s *batchStream) Context() context.Context { return context.Background() }
+
+func (s *batchStream) Recv() (*pb.BatchCreateUsersRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *batchStream) Send(resp *pb.BatchCreateUsersResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+func TestBatchCreateUsers(t *testing.T) {
+	valid := func(name string) *pb.User {
+		return &pb.User{Fname: name, City: "SF", Phone: "9123456789", Height: 5.4}
+	}
+	large := make([]*pb.User, batchChunk+10)
+	for i := range large {
+		large[i] = valid("Bulk")
+	}
+
+	userServer := NewUserServer()
+	stream := &batchStream{reqs: []*pb.BatchCreateUsersRequest{
+		{Users: []*pb.User{valid("Carol"), {Fname: "Eve", City: "SF", Phone: "123", Height: 5}, nil, valid("Dave")}},
+		{Users: large},
+	}}
+
+	err := userServer.BatchCreateUsers(stream)
+
+	assert.NoError(t, err)
+	if assert.Len(t, stream.resps, 2) {
+		first := stream.resps[0].Results
+		assert.Equal(t, uint32(4), first[0].GetId())
+		assert.Equal(t, "error: invalid field(s): phone", first[1].GetError())
+		assert.Equal(t, "error: invalid field(s): user must be provided", first[2].GetError())
+		assert.Equal(t, uint32(3), first[3].Index)
+		assert.Equal(t, uint32(5), first[3].GetId())
+
+		second := stream.resps[1].Results
+		assert.Len(t, second, len(large))
+		assert.Equal(t, uint32(4), second[0].Index)
+		assert.Equal(t, uint32(6), second[0].GetId())
+		assert.Equal(t, uint32(6+len(large)-1), second[len(large)-1].GetId())
+	}
+	assert.Equal(t, 3+2+len(large), userServer.Stats().Users)
+	assert.Equal(t, "Carol", userServer.users[4].Fname)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// ImportUsers writes every valid row of the stream and reports the rows that
+// failed instead of aborting. Each row takes the store lock on its own so
+// readers are not blocked for the length of the import.
+func (s *UserServer) ImportUsers(stream pb.UserService_ImportUsersServer) error {
+	span := trace.SpanFromContext(stream.Context())
+	actor := actorOf(stream.Context())
+	resp := &pb.ImportUsersResponse{}
+	var mode pb.ImportUsersRequest_Mode
+
+	// IDs written by this import, so a dry run also catches a file that
+	// repeats an ID
+	seen := make(map[uint32]struct{})
+	for first := true; ; first = false {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			span.SetAttributes(resultCountAttribute(int(resp.Created + resp.Updated)))
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			mode, resp.DryRun = req.Mode, req.DryRun
+		}
+
+		updated, err := s.importUser(actor, req.User, mode, resp.DryRun, seen)
+		switch {
+		case err != nil:
+			resp.Failed++
+			resp.Errors = append(resp.Errors, &pb.ImportError{Line: req.Line, Error: err.Error()})
+		case updated:
+			resp.Updated++
+		default:
+			resp.Created++
+		}
+	}
+}
+
+// importUser validates and stores one imported user, reporting whether it
+// replaced an existing one. Users without an ID get the next free one.
+func (s *UserServer) importUser(actor string, user *pb.User, mode pb.ImportUsersRequest_Mode, dryRun bool, seen map[uint32]struct{}) (bool, error) {
+	if err := utils.ValidateUser(user); err != nil {
+		return false, err
+	}
+
+	s.lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkAttributes(user); err != nil {
+		return false, err
+	}
+	if user.Id == 0 {
+		if err := s.checkUnique(user, nil); err != nil {
+			return false, err
+		}
+		if err := s.checkQuota(1); err != nil {
+			return false, err
+		}
+		if !dryRun {
+			id, err := s.allocateID()
+			if err != nil {
+				return false, err
+			}
+			user = proto.Clone(user).(*pb.User)
+			user.Id = id
+			user.Version = 1
+			s.users[user.Id] = user
+			s.index(user)
+			s.changed(actor, pb.UserEvent_CREATED, nil, user)
+		}
+		return false, nil
+	}
+
+	if user.Id == math.MaxUint32 {
+		return false, fmt.Errorf("%w: %d is reserved", errors.ErrInvalidID, user.Id)
+	}
+	if _, erased := s.erasures[user.Id]; erased {
+		return false, fmt.Errorf("%w: %d was erased", errors.ErrInvalidID, user.Id)
+	}
+	existing, found := s.users[user.Id]
+	if _, imported := seen[user.Id]; imported {
+		found = true
+	}
+	if found && mode == pb.ImportUsersRequest_INSERT {
+		return false, fmt.Errorf("%w: %d", errors.ErrUserExists, user.Id)
+	}
+	if err := s.checkUnique(user, existing); err != nil {
+		return false, err
+	}
+	if existing == nil {
+		if err := s.checkQuota(1); err != nil {
+			return false, err
+		}
+	}
+	seen[user.Id] = struct{}{}
+	if dryRun {
+		return found, nil
+	}
+
+	user = proto.Clone(user).(*pb.User)
+	user.Version = 1
+	if existing != nil {
+		user.Version = existing.Version + 1
+		s.unindex(existing)
+	}
+	s.users[user.Id] = user
+	s.index(user)
+	s.nextID = max(s.nextID, user.Id+1)
+	if found {
+		s.changed(actor, pb.UserEvent_UPDATED, existing, user)
+	} else {
+		s.changed(actor, pb.UserEvent_CREATED, nil, user)
+	}
+	return found, nil
+}
+
+// ExportUsers streams every user ordered by ID. Users are read from a
+// snapshot taken when the export starts, so the lock is not held while
+// sending.
+func (s *UserServer) ExportUsers(req *pb.ExportUsersRequest, stream pb.UserService_ExportUsersServer) error {
+	_, read := tracer.Start(stream.Context(), "store.export")
+	s.lock()
+	users := make([]*pb.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	s.mu.Unlock()
+	read.End()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	trace.SpanFromContext(stream.Context()).SetAttributes(resultCountAttribute(len(users)))
+	for _, user := range users {
+		if err := stream.Send(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
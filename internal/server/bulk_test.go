@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+
+	"user-service-module/internal/errors"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type importStream struct {
+	grpc.ServerStream
+	reqs []*pb.ImportUsersRequest
+	resp *pb.ImportUsersResponse
+}
+
+func (s *importStream) Context() context.Context { return context.Background() }
+
+func (s *importStream) Recv() (*pb.ImportUsersRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *importStream) SendAndClose(resp *pb.ImportUsersResponse) error {
+	s.resp = resp
+	return nil
+}
+
+type exportStream struct {
+	grpc.ServerStream
+	users []*pb.User
+}
+
+func (s *exportStream) Context() context.Context { return context.Background() }
+
+func (s *exportStream) Send(user *pb.User) error {
+	s.users = append(s.users, user)
+	return nil
+}
+
+func TestImportUsers(t *testing.T) {
+	carol := &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}
+	steve := &pb.User{Id: 1, Fname: "Steve", City: "Boston", Phone: "9827329211", Height: 5.8}
+	dave := &pb.User{Id: 10, Fname: "Dave", City: "NY", Phone: "9000000001", Height: 6}
+	invalid := &pb.User{Fname: "Eve", City: "SF", Phone: "123", Height: 5}
+
+	tests := []struct {
+		name         string
+		mode         pb.ImportUsersRequest_Mode
+		dryRun       bool
+		users        []*pb.User
+		want         *pb.ImportUsersResponse
+		wantCities   map[uint32]string
+		wantVersions map[uint32]uint32
+		wantNextID   uint32
+		wantInLA     []uint32
+	}{
+		{
+			name:  "insert reports existing and invalid rows",
+			mode:  pb.ImportUsersRequest_INSERT,
+			users: []*pb.User{carol, steve, dave, invalid, dave},
+			want: &pb.ImportUsersResponse{Created: 2, Failed: 3, Errors: []*pb.ImportError{
+				{Line: 2, Error: "error: user(s) already exist: 1"},
+				{Line: 4, Error: "error: invalid field(s): phone"},
+				{Line: 5, Error: "error: user(s) already exist: 10"},
+			}},
+			wantCities:   map[uint32]string{1: "LA", 4: "SF", 10: "NY"},
+			wantVersions: map[uint32]uint32{1: 1, 4: 1, 10: 1},
+			wantNextID:   11,
+			wantInLA:     []uint32{1, 3},
+		},
+		{
+			name:         "upsert replaces existing users",
+			mode:         pb.ImportUsersRequest_UPSERT,
+			users:        []*pb.User{steve, dave, dave},
+			want:         &pb.ImportUsersResponse{Created: 1, Updated: 2},
+			wantCities:   map[uint32]string{1: "Boston", 10: "NY"},
+			wantVersions: map[uint32]uint32{1: 2, 10: 2},
+			wantNextID:   11,
+			wantInLA:     []uint32{3},
+		},
+		{
+			name:       "dry run writes nothing",
+			mode:       pb.ImportUsersRequest_INSERT,
+			dryRun:     true,
+			users:      []*pb.User{carol, steve, dave, dave},
+			want:       &pb.ImportUsersResponse{Created: 2, Failed: 2, DryRun: true, Errors: []*pb.ImportError{{Line: 2, Error: "error: user(s) already exist: 1"}, {Line: 4, Error: "error: user(s) already exist: 10"}}},
+			wantCities: map[uint32]string{1: "LA"},
+			wantNextID: 4,
+			wantInLA:   []uint32{1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userServer := NewUserServer()
+			stream := &importStream{}
+			for i, u := range tt.users {
+				stream.reqs = append(stream.reqs, &pb.ImportUsersRequest{Mode: tt.mode, DryRun: tt.dryRun, Line: uint32(i + 1), User: u})
+			}
+
+			err := userServer.ImportUsers(stream)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want.String(), stream.resp.String())
+			for id, city := range tt.wantCities {
+				assert.Equal(t, city, userServer.users[id].City)
+			}
+			for id, version := range tt.wantVersions {
+				assert.Equal(t, version, userServer.users[id].Version)
+			}
+			assert.Equal(t, tt.wantNextID, userServer.nextID)
+			assert.Equal(t, tt.wantInLA, userServer.search(&pb.SearchUsersRequest{City: "la"}))
+		})
+	}
+}
+
+func TestImportUsersLastID(t *testing.T) {
+	userServer := NewUserServer()
+	last := &pb.User{Id: math.MaxUint32 - 1, Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}
+	reserved := &pb.User{Id: math.MaxUint32, Fname: "Dave", City: "NY", Phone: "9000000001", Height: 6}
+	stream := &importStream{reqs: []*pb.ImportUsersRequest{{Line: 1, User: last}, {Line: 2, User: reserved}}}
+
+	assert.NoError(t, userServer.ImportUsers(stream))
+	assert.Equal(t, uint32(1), stream.resp.Created)
+	assert.Equal(t, []*pb.ImportError{{Line: 2, Error: "error: invalid ID(s): 4294967295 is reserved"}}, stream.resp.Errors)
+
+	resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: &pb.User{Fname: "Eve", City: "SF", Phone: "9000000002", Height: 5}})
+	assert.ErrorIs(t, err, errors.ErrQuotaExceeded)
+	assert.Equal(t, uint32(429), resp.StatusCode)
+	assert.Equal(t, 4, userServer.Stats().Users)
+}
+
+func TestExportUsers(t *testing.T) {
+	userServer := NewUserServer()
+	stream := &exportStream{}
+
+	err := userServer.ExportUsers(&pb.ExportUsersRequest{}, stream)
+
+	assert.NoError(t, err)
+	var ids []uint32
+	for _, u := range stream.users {
+		ids = append(ids, u.Id)
+	}
+	assert.Equal(t, []uint32{1, 2, 3}, ids)
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// History page sizes.
+const (
+	defaultHistoryPage = 50
+	maxHistoryPage     = 500
+)
+
+// seedActor is recorded as the author of the sample users.
+const seedActor = "system"
+
+// actorOf returns the subject recorded as the author of a change.
+func actorOf(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		return id.Subject
+	}
+	return auth.AnonymousSubject
+}
+
+// changed records a write in the history of the user and notifies watchers.
+// before is nil for a creation. The server-managed times of a created or
+// updated user are set to the time of the revision, so after must not have
+// been handed out yet. The caller must hold mu.
+func (s *UserServer) changed(actor string, eventType pb.UserEvent_Type, before, after *pb.User) {
+	user := after
+	if eventType == pb.UserEvent_DELETED {
+		user = before
+	}
+	history := s.history[user.Id]
+
+	// Keep revisions ordered by time for as-of lookups even if the clock
+	// steps back
+	now := s.now()
+	if len(history) > 0 {
+		if last := history[len(history)-1].Time.AsTime(); now.Before(last) {
+			now = last
+		}
+	}
+
+	revision := &pb.UserRevision{Type: eventType, Time: timestamppb.New(now), Actor: actor, User: user}
+	if eventType != pb.UserEvent_DELETED {
+		after.CreateTime = before.GetCreateTime()
+		if after.CreateTime == nil {
+			after.CreateTime = revision.Time
+		}
+		after.UpdateTime = revision.Time
+		revision.Changes = diff(before, after)
+	}
+	s.history[user.Id] = append(history, revision)
+	s.publish(eventType, user)
+}
+
+// diff lists the fields other than the server-managed version and times that
+// differ between before and after. A nil before is treated as an empty user.
+func diff(before, after *pb.User) []*pb.FieldChange {
+	if before == nil {
+		before = &pb.User{}
+	}
+	old, updated := before.ProtoReflect(), after.ProtoReflect()
+	fields := old.Descriptor().Fields()
+
+	var changes []*pb.FieldChange
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if serverManaged[fd.Name()] || old.Get(fd).Equal(updated.Get(fd)) {
+			continue
+		}
+		changes = append(changes, &pb.FieldChange{
+			Field: string(fd.Name()),
+			Old:   formatField(old, fd),
+			New:   formatField(updated, fd),
+		})
+	}
+	return changes
+}
+
+// serverManaged names the User fields set by the server on every write.
+var serverManaged = map[protoreflect.Name]bool{"version": true, "createTime": true, "updateTime": true}
+
+// formatField formats a field value as text, or returns "" when it is unset.
+func formatField(m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	if !m.Has(fd) {
+		return ""
+	}
+	v := m.Get(fd)
+	if fd.IsMap() {
+		return formatMap(v.Map())
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case protoreflect.MessageKind:
+		switch msg := v.Message().Interface().(type) {
+		case *timestamppb.Timestamp:
+			return msg.AsTime().Format(time.RFC3339Nano)
+		case *pb.Address:
+			return formatAddress(msg)
+		}
+		return v.String()
+	default:
+		return v.String()
+	}
+}
+
+// formatAddress joins the set fields of an address, such as
+// "1 Main St, California, 94105, US".
+func formatAddress(a *pb.Address) string {
+	var parts []string
+	for _, part := range []string{a.Street, a.Region, a.PostalCode, a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMap formats map entries as "key=value" sorted by key, such as
+// "level=3, team=core".
+func formatMap(m protoreflect.Map) string {
+	var keys []string
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k.String())
+		return true
+	})
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + m.Get(protoreflect.ValueOfString(k).MapKey()).String()
+	}
+	return strings.Join(keys, ", ")
+}
+
+// GetUserHistory returns the revisions of a user from oldest to newest, one
+// page at a time, or the single revision in effect at req.AsOf. History is
+// kept after a user is deleted.
+func (s *UserServer) GetUserHistory(ctx context.Context, req *pb.GetUserHistoryRequest) (*pb.GetUserHistoryResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(userIDsAttribute(req.Id))
+
+	if !utils.IsIDValid(req.Id) {
+		return &pb.GetUserHistoryResponse{
+			StatusCode: http.StatusBadRequest,
+		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.Id)
+	}
+	start := 0
+	if req.AsOf == nil && req.PageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(req.PageToken); err != nil || start < 0 {
+			return &pb.GetUserHistoryResponse{
+				StatusCode: http.StatusBadRequest,
+			}, fmt.Errorf("%w: %v", errors.ErrInvalidFields, "invalid pageToken")
+		}
+	}
+
+	_, lookup := tracer.Start(ctx, "store.history")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	history, found := s.history[req.Id]
+	if !found {
+		return &pb.GetUserHistoryResponse{
+			StatusCode: http.StatusNotFound,
+		}, fmt.Errorf("%w: %d", errors.ErrUserNotFound, req.Id)
+	}
+
+	if req.AsOf != nil {
+		asOf := req.AsOf.AsTime()
+		// Index of the first revision after asOf
+		i := sort.Search(len(history), func(i int) bool { return history[i].Time.AsTime().After(asOf) })
+		if i == 0 || history[i-1].Type == pb.UserEvent_DELETED {
+			return &pb.GetUserHistoryResponse{
+				StatusCode: http.StatusNotFound,
+			}, fmt.Errorf("%w: %d did not exist at %s", errors.ErrUserNotFound, req.Id, asOf.Format(time.RFC3339Nano))
+		}
+		span.SetAttributes(resultCountAttribute(1))
+		return &pb.GetUserHistoryResponse{
+			StatusCode: http.StatusOK,
+			Revisions:  []*pb.UserRevision{history[i-1]},
+		}, nil
+	}
+
+	size := int(req.PageSize)
+	if size == 0 {
+		size = defaultHistoryPage
+	}
+	size = min(size, maxHistoryPage)
+	start = min(start, len(history))
+	end := min(start+size, len(history))
+
+	resp := &pb.GetUserHistoryResponse{
+		StatusCode: http.StatusOK,
+		Revisions:  history[start:end:end],
+	}
+	if end < len(history) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	span.SetAttributes(resultCountAttribute(len(resp.Revisions)))
+	return resp, nil
+}
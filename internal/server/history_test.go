@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// historyServer returns a server whose clock advances one minute per write
+// from start, after creating, updating and deleting user 4.
+func historyServer(t *testing.T, start time.Time) *UserServer {
+	t.Helper()
+	userServer := NewUserServer()
+	now := start
+	userServer.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	alice := auth.NewContext(context.Background(), &auth.Identity{Subject: "alice"})
+	bob := auth.NewContext(context.Background(), &auth.Identity{Subject: "bob"})
+
+	_, err := userServer.CreateUser(alice, &pb.CreateUserRequest{User: &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}})
+	assert.NoError(t, err)
+	_, err = userServer.UpdateUser(bob, &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 4, City: "Boston", IsMarried: pb.MaritalStatus_MARRIED},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"city", "isMarried"}},
+	})
+	assert.NoError(t, err)
+	_, err = userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 4})
+	assert.NoError(t, err)
+	return userServer
+}
+
+func TestGetUserHistory(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	userServer := historyServer(t, start)
+
+	resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(200), resp.StatusCode)
+	assert.Empty(t, resp.NextPageToken)
+	if !assert.Len(t, resp.Revisions, 3) {
+		return
+	}
+
+	created, updated, deleted := resp.Revisions[0], resp.Revisions[1], resp.Revisions[2]
+	assert.Equal(t, pb.UserEvent_CREATED, created.Type)
+	assert.Equal(t, "alice", created.Actor)
+	assert.Equal(t, start.Add(time.Minute), created.Time.AsTime())
+	assert.Equal(t, uint32(1), created.User.Version)
+	assert.Equal(t, []string{"id:  → 4", "fname:  → Carol", "city:  → SF", "phone:  → 9123456789", "height:  → 5.4"}, changeStrings(created.Changes))
+
+	assert.Equal(t, pb.UserEvent_UPDATED, updated.Type)
+	assert.Equal(t, "bob", updated.Actor)
+	assert.Equal(t, uint32(2), updated.User.Version)
+	assert.Equal(t, []string{"city: SF → Boston", "isMarried:  → MARRIED"}, changeStrings(updated.Changes))
+
+	assert.Equal(t, pb.UserEvent_DELETED, deleted.Type)
+	assert.Equal(t, auth.AnonymousSubject, deleted.Actor)
+	assert.Equal(t, "Boston", deleted.User.City)
+	assert.Empty(t, deleted.Changes)
+}
+
+// changeStrings formats changes as "field: old → new".
+func changeStrings(changes []*pb.FieldChange) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.Field+": "+c.Old+" → "+c.New)
+	}
+	return out
+}
+
+func TestGetUserHistoryPages(t *testing.T) {
+	userServer := historyServer(t, time.Now())
+
+	var versions []uint32
+	token := ""
+	for pages := 0; ; pages++ {
+		resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 4, PageSize: 2, PageToken: token})
+		assert.NoError(t, err)
+		for _, r := range resp.Revisions {
+			versions = append(versions, r.User.Version)
+		}
+		if token = resp.NextPageToken; token == "" {
+			assert.Equal(t, 1, pages, "three revisions should fit on two pages")
+			break
+		}
+	}
+	assert.Equal(t, []uint32{1, 2, 2}, versions)
+}
+
+func TestGetUserHistoryAsOf(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	userServer := historyServer(t, start)
+
+	tests := []struct {
+		name        string
+		asOf        time.Time
+		wantVersion uint32
+		wantErr     error
+	}{
+		{name: "should not find the user before creation", asOf: start, wantErr: errors.ErrUserNotFound},
+		{name: "should find the created user", asOf: start.Add(time.Minute), wantVersion: 1},
+		{name: "should find the user between writes", asOf: start.Add(150 * time.Second), wantVersion: 2},
+		{name: "should not find the user after deletion", asOf: start.Add(time.Hour), wantErr: errors.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 4, AsOf: timestamppb.New(tt.asOf)})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, uint32(404), resp.StatusCode)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, resp.Revisions, 1) {
+				assert.Equal(t, tt.wantVersion, resp.Revisions[0].User.Version)
+			}
+		})
+	}
+}
+
+func TestGetUserHistoryErrors(t *testing.T) {
+	userServer := NewUserServer()
+
+	tests := []struct {
+		name         string
+		req          *pb.GetUserHistoryRequest
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{"should return error for invalid ID", &pb.GetUserHistoryRequest{Id: 0}, 400, errors.ErrInvalidID},
+		{"should return error for unknown user", &pb.GetUserHistoryRequest{Id: 999}, 404, errors.ErrUserNotFound},
+		{"should return error for invalid page token", &pb.GetUserHistoryRequest{Id: 1, PageToken: "abc"}, 400, errors.ErrInvalidFields},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.GetUserHistory(context.Background(), tt.req)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestHistoryOfSeedUsers(t *testing.T) {
+	userServer := NewUserServer()
+
+	resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Revisions, 1) {
+		assert.Equal(t, seedActor, resp.Revisions[0].Actor)
+		assert.Equal(t, pb.UserEvent_CREATED, resp.Revisions[0].Type)
+	}
+}
+
+func TestHistoryOfProfileFields(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		User:       &pb.User{Id: 2, Email: "bob@example.com", Address: &pb.Address{Street: "1 Main St", PostalCode: "10001", Country: "US"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email", "address"}},
+	})
+	assert.NoError(t, err)
+
+	resp, err := userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Revisions, 2) {
+		assert.Equal(t, []string{"email:  → bob@example.com", "address:  → 1 Main St, 10001, US"}, changeStrings(resp.Revisions[1].Changes))
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/gdpr"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ArchivePublicKey returns the key verifying the archives returned by
+// ExportUserData.
+func (s *UserServer) ArchivePublicKey() ed25519.PublicKey {
+	return s.archiveKey.Public().(ed25519.PublicKey)
+}
+
+// ExportUserData returns a signed archive of everything held about a user:
+// the record, its history and the audit records of reads of it. Deleted
+// users are exported from their history until they are erased.
+func (s *UserServer) ExportUserData(ctx context.Context, req *pb.ExportUserDataRequest) (*pb.ExportUserDataResponse, error) {
+	trace.SpanFromContext(ctx).SetAttributes(userIDsAttribute(req.Id))
+
+	if !utils.IsIDValid(req.Id) {
+		return &pb.ExportUserDataResponse{
+			StatusCode: http.StatusBadRequest,
+		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.Id)
+	}
+
+	_, lookup := tracer.Start(ctx, "store.export_data")
+	s.lock()
+	contents := gdpr.Contents{UserID: req.Id, Tenant: s.tenant, User: s.users[req.Id], History: s.history[req.Id]}
+	s.mu.Unlock()
+	lookup.End()
+	if len(contents.History) == 0 {
+		return &pb.ExportUserDataResponse{
+			StatusCode: http.StatusNotFound,
+		}, fmt.Errorf("%w: %d", errors.ErrUserNotFound, req.Id)
+	}
+
+	if s.auditRecords != nil {
+		_, read := tracer.Start(ctx, "audit.find")
+		records, err := s.auditRecords(req.Id)
+		read.End()
+		if err != nil {
+			return &pb.ExportUserDataResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, fmt.Errorf("read audit log: %w", err)
+		}
+		contents.Audit = records
+	}
+
+	archive, err := gdpr.Build(contents, s.archiveKey, s.now())
+	if err != nil {
+		return &pb.ExportUserDataResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("build archive: %w", err)
+	}
+	return &pb.ExportUserDataResponse{
+		StatusCode: http.StatusOK,
+		Archive:    archive,
+	}, nil
+}
+
+// EraseUser permanently removes a user, live or deleted, along with its
+// history and any cached responses holding it, and keeps only a receipt of
+// the erasure. Erasing an erased user
+// returns the original receipt.
+func (s *UserServer) EraseUser(ctx context.Context, req *pb.EraseUserRequest) (*pb.EraseUserResponse, error) {
+	trace.SpanFromContext(ctx).SetAttributes(userIDsAttribute(req.Id))
+
+	if !utils.IsIDValid(req.Id) {
+		return &pb.EraseUserResponse{
+			StatusCode: http.StatusBadRequest,
+		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.Id)
+	}
+
+	_, write := tracer.Start(ctx, "store.erase")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	if receipt, erased := s.erasures[req.Id]; erased {
+		return &pb.EraseUserResponse{
+			StatusCode: http.StatusOK,
+			Receipt:    receipt,
+		}, nil
+	}
+	history, found := s.history[req.Id]
+	if !found {
+		return &pb.EraseUserResponse{
+			StatusCode: http.StatusNotFound,
+		}, fmt.Errorf("%w: %d", errors.ErrUserNotFound, req.Id)
+	}
+
+	if user, live := s.users[req.Id]; live {
+		delete(s.users, req.Id)
+		s.unindex(user)
+		// Watchers learn that the user is gone, but not what it held
+		s.publish(pb.UserEvent_DELETED, &pb.User{Id: req.Id})
+	}
+	delete(s.history, req.Id)
+	forgotten := 0
+	if s.forgetResponses != nil {
+		forgotten = s.forgetResponses(req.Id)
+	}
+
+	receipt := &pb.ErasureReceipt{
+		Id:                    req.Id,
+		Time:                  timestamppb.New(s.now()),
+		Actor:                 actorOf(ctx),
+		Reason:                req.Reason,
+		RevisionsErased:       uint32(len(history)),
+		CachedResponsesErased: uint32(forgotten),
+	}
+	s.erasures[req.Id] = receipt
+	return &pb.EraseUserResponse{
+		StatusCode: http.StatusOK,
+		Receipt:    receipt,
+	}, nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/internal/gdpr"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportUserData(t *testing.T) {
+	audited := []json.RawMessage{json.RawMessage(`{"seq":7,"userIds":[2]}`)}
+	var asked uint32
+	userServer := NewUserServer(WithAuditRecords(func(id uint32) ([]json.RawMessage, error) {
+		asked = id
+		return audited, nil
+	}))
+
+	resp, err := userServer.ExportUserData(context.Background(), &pb.ExportUserDataRequest{Id: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(200), resp.StatusCode)
+	assert.Equal(t, uint32(2), asked)
+
+	manifest, err := gdpr.Verify(resp.Archive, userServer.ArchivePublicKey())
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), manifest.UserID)
+	var names []string
+	for _, f := range manifest.Files {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{gdpr.UserFile, gdpr.HistoryFile, gdpr.AuditFile}, names)
+}
+
+func TestExportUserDataOfDeletedUser(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 2})
+	assert.NoError(t, err)
+
+	resp, err := userServer.ExportUserData(context.Background(), &pb.ExportUserDataRequest{Id: 2})
+	assert.NoError(t, err)
+	manifest, err := gdpr.Verify(resp.Archive, userServer.ArchivePublicKey())
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Files, 2, "the history should be exported without a user record")
+}
+
+func TestExportUserDataErrors(t *testing.T) {
+	userServer := NewUserServer()
+
+	tests := []struct {
+		name         string
+		id           uint32
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{"should return error for invalid ID", 0, 400, errors.ErrInvalidID},
+		{"should return error for unknown user", 999, 404, errors.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.ExportUserData(context.Background(), &pb.ExportUserDataRequest{Id: tt.id})
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestEraseUser(t *testing.T) {
+	userServer := NewUserServer()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	userServer.now = func() time.Time { return now }
+	w := userServer.subscribe(nil)
+	defer userServer.unsubscribe(w)
+	ctx := auth.NewContext(context.Background(), &auth.Identity{Subject: "dpo"})
+
+	_, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{User: &pb.User{Id: 2, Fname: "Bob", City: "Boston", Phone: "9876543210", Height: 6.1}})
+	assert.NoError(t, err)
+	<-w.events
+
+	resp, err := userServer.EraseUser(ctx, &pb.EraseUserRequest{Id: 2, Reason: "ticket 42"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(200), resp.StatusCode)
+	assert.Equal(t, uint32(2), resp.Receipt.Id)
+	assert.Equal(t, now, resp.Receipt.Time.AsTime())
+	assert.Equal(t, "dpo", resp.Receipt.Actor)
+	assert.Equal(t, "ticket 42", resp.Receipt.Reason)
+	assert.Equal(t, uint32(2), resp.Receipt.RevisionsErased)
+
+	event := <-w.events
+	assert.Equal(t, pb.UserEvent_DELETED, event.Type)
+	assert.Equal(t, &pb.User{Id: 2}, event.User, "watchers should only learn the ID")
+
+	_, err = userServer.GetUser(ctx, &pb.GetUserRequest{Id: 2})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = userServer.GetUserHistory(ctx, &pb.GetUserHistoryRequest{Id: 2})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = userServer.ExportUserData(ctx, &pb.ExportUserDataRequest{Id: 2})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	assert.Empty(t, userServer.search(&pb.SearchUsersRequest{City: "Boston", Phone: "9876543210"}))
+
+	again, err := userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: 2})
+	assert.NoError(t, err)
+	assert.Same(t, resp.Receipt, again.Receipt, "erasing again should return the first receipt")
+}
+
+func TestEraseDeletedUser(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 3})
+	assert.NoError(t, err)
+
+	resp, err := userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), resp.Receipt.RevisionsErased)
+	_, err = userServer.GetUserHistory(context.Background(), &pb.GetUserHistoryRequest{Id: 3})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+}
+
+func TestEraseUserErrors(t *testing.T) {
+	userServer := NewUserServer()
+
+	tests := []struct {
+		name         string
+		id           uint32
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{"should return error for invalid ID", 0, 400, errors.ErrInvalidID},
+		{"should return error for unknown user", 999, 404, errors.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: tt.id})
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestImportRejectsErasedUser(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: 1})
+	assert.NoError(t, err)
+
+	stream := &importStream{reqs: []*pb.ImportUsersRequest{{
+		Mode: pb.ImportUsersRequest_UPSERT,
+		User: &pb.User{Id: 1, Fname: "Steve", City: "LA", Phone: "9827329211", Height: 5.8},
+	}}}
+	assert.NoError(t, userServer.ImportUsers(stream))
+	assert.Equal(t, uint32(1), stream.resp.Failed)
+	_, err = userServer.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+}
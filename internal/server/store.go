@@ -0,0 +1,273 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Stats is a point-in-time view of the user store.
+type Stats struct {
+	Users            int           `json:"users"`
+	CityIndexKeys    int           `json:"city_index_keys"`
+	PhoneIndexKeys   int           `json:"phone_index_keys"`
+	MarriedIndexKeys int           `json:"married_index_keys"`
+	LnameIndexKeys   int           `json:"lname_index_keys"`
+	EmailIndexKeys   int           `json:"email_index_keys"`
+	BirthIndexKeys   int           `json:"date_of_birth_index_keys"`
+	CountryIndexKeys int           `json:"country_index_keys"`
+	AttrIndexKeys    int           `json:"attribute_index_keys"`
+	LockWait         time.Duration `json:"lock_wait_ns"`
+	LockAcquisitions uint64        `json:"lock_acquisitions"`
+	Tenants          int           `json:"tenants,omitempty"`
+}
+
+// Stats returns the current store statistics.
+func (s *UserServer) Stats() Stats {
+	s.lock()
+	defer s.mu.Unlock()
+
+	return Stats{
+		Users:            len(s.users),
+		CityIndexKeys:    len(s.byCity),
+		PhoneIndexKeys:   len(s.byPhone),
+		MarriedIndexKeys: len(s.byMarried),
+		LnameIndexKeys:   len(s.byLname),
+		EmailIndexKeys:   len(s.byEmail),
+		BirthIndexKeys:   len(s.byBirthDate),
+		CountryIndexKeys: len(s.byCountry),
+		AttrIndexKeys:    s.attributeIndexKeys(),
+		LockWait:         time.Duration(s.lockWait.Load()),
+		LockAcquisitions: s.lockAcquisitions.Load(),
+	}
+}
+
+// lock acquires mu, recording how long the caller waited for it.
+func (s *UserServer) lock() {
+	start := time.Now()
+	s.mu.Lock()
+	s.lockWait.Add(int64(time.Since(start)))
+	s.lockAcquisitions.Add(1)
+}
+
+// index adds user to the secondary indexes. The caller must hold mu.
+func (s *UserServer) index(user *pb.User) {
+	addToIndex(s.byCity, strings.ToLower(user.City), user.Id)
+	addToIndex(s.byPhone, user.Phone, user.Id)
+	addToIndex(s.byMarried, user.IsMarried, user.Id)
+	if user.Lname != "" {
+		addToIndex(s.byLname, strings.ToLower(user.Lname), user.Id)
+	}
+	if user.Email != "" {
+		addToIndex(s.byEmail, strings.ToLower(user.Email), user.Id)
+	}
+	if user.DateOfBirth != "" {
+		addToIndex(s.byBirthDate, user.DateOfBirth, user.Id)
+	}
+	if country := user.GetAddress().GetCountry(); country != "" {
+		addToIndex(s.byCountry, country, user.Id)
+	}
+	for key, value := range user.Attributes {
+		if index, indexed := s.byAttribute[key]; indexed {
+			addToIndex(index, value, user.Id)
+		}
+	}
+}
+
+// unindex removes user from the secondary indexes. The caller must hold mu.
+func (s *UserServer) unindex(user *pb.User) {
+	removeFromIndex(s.byCity, strings.ToLower(user.City), user.Id)
+	removeFromIndex(s.byPhone, user.Phone, user.Id)
+	removeFromIndex(s.byMarried, user.IsMarried, user.Id)
+	removeFromIndex(s.byLname, strings.ToLower(user.Lname), user.Id)
+	removeFromIndex(s.byEmail, strings.ToLower(user.Email), user.Id)
+	removeFromIndex(s.byBirthDate, user.DateOfBirth, user.Id)
+	removeFromIndex(s.byCountry, user.GetAddress().GetCountry(), user.Id)
+	for key, value := range user.Attributes {
+		if index, indexed := s.byAttribute[key]; indexed {
+			removeFromIndex(index, value, user.Id)
+		}
+	}
+}
+
+// attributeIndexKeys counts the distinct values of every indexed attribute.
+// The caller must hold mu.
+func (s *UserServer) attributeIndexKeys() int {
+	n := 0
+	for _, index := range s.byAttribute {
+		n += len(index)
+	}
+	return n
+}
+
+// checkQuota reports whether n more users fit in the store. The caller must
+// hold mu.
+func (s *UserServer) checkQuota(n int) error {
+	if s.maxUsers > 0 && len(s.users)+n > s.maxUsers {
+		return fmt.Errorf("%w: tenant %s holds at most %d users", errors.ErrQuotaExceeded, s.tenant, s.maxUsers)
+	}
+	return nil
+}
+
+// allocateID returns the lowest free ID from nextID on and moves nextID past
+// it. An ID is taken while a user, deleted or not, or an erasure receipt holds
+// it, so IDs are never reused. math.MaxUint32 is never allocated, which keeps
+// nextID from wrapping. The caller must hold mu.
+func (s *UserServer) allocateID() (uint32, error) {
+	for ; s.nextID < math.MaxUint32; s.nextID++ {
+		_, live := s.users[s.nextID]
+		_, deleted := s.history[s.nextID]
+		_, erased := s.erasures[s.nextID]
+		if !live && !deleted && !erased {
+			s.nextID++
+			return s.nextID - 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: tenant %s has no user IDs left", errors.ErrQuotaExceeded, s.tenant)
+}
+
+// checkVersion reports whether user is at the expected version. An expected
+// version of 0 matches any user.
+func checkVersion(user *pb.User, expected uint32) error {
+	if expected != 0 && user.Version != expected {
+		return fmt.Errorf("%w: user %d is at version %d, want %d", errors.ErrVersionMismatch, user.Id, user.Version, expected)
+	}
+	return nil
+}
+
+// applyUpdate returns a copy of existing with the fields named in paths taken
+// from update, at the next version. Every field except the id and version is
+// replaced when paths is empty.
+func applyUpdate(existing, update *pb.User, paths []string) (*pb.User, error) {
+	updated := proto.Clone(existing).(*pb.User)
+	if len(paths) == 0 {
+		updated = proto.Clone(update).(*pb.User)
+		updated.Id = existing.Id
+		updated.Version = existing.Version + 1
+		return updated, nil
+	}
+
+	for _, path := range paths {
+		if path == "id" || path == "version" || path == "createTime" || path == "updateTime" || !setPath(updated.ProtoReflect(), update.ProtoReflect(), path) {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidFields, "cannot update "+path)
+		}
+	}
+	updated.Version = existing.Version + 1
+	return updated, nil
+}
+
+// setPath copies the field at path, which may name a field of a nested
+// message such as "address.country" or an entry of a map such as
+// "attributes.team", from src to dst. It reports false when path does not
+// name a field.
+func setPath(dst, src protoreflect.Message, path string) bool {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := dst.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return false
+		}
+		if fd.IsMap() && i == len(names)-2 && fd.MapKey().Kind() == protoreflect.StringKind {
+			key := protoreflect.ValueOfString(names[i+1]).MapKey()
+			if value := src.Get(fd).Map().Get(key); value.IsValid() {
+				dst.Mutable(fd).Map().Set(key, value)
+			} else if dst.Has(fd) {
+				dst.Mutable(fd).Map().Clear(key)
+			}
+			return true
+		}
+		if i == len(names)-1 {
+			if src.Has(fd) {
+				dst.Set(fd, src.Get(fd))
+			} else {
+				dst.Clear(fd)
+			}
+			return true
+		}
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return false
+		}
+		dst, src = dst.Mutable(fd).Message(), src.Get(fd).Message()
+	}
+	return false
+}
+
+// search returns the IDs, in ascending order, of users matching any of the
+// criteria set in req. The caller must hold mu.
+func (s *UserServer) search(req *pb.SearchUsersRequest) []uint32 {
+	matched := make(map[uint32]struct{})
+	if req.City != "" {
+		for id := range s.byCity[strings.ToLower(req.City)] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.Phone != "" {
+		for id := range s.byPhone[req.Phone] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.IsMarried != pb.MaritalStatus_UNKNOWN {
+		for id := range s.byMarried[req.IsMarried] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.UnknownMaritalStatus {
+		for id := range s.byMarried[pb.MaritalStatus_UNKNOWN] {
+			matched[id] = struct{}{}
+		}
+	}
+	for key, value := range req.Attributes {
+		for id := range s.byAttribute[key][value] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.Lname != "" {
+		for id := range s.byLname[strings.ToLower(req.Lname)] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.Email != "" {
+		for id := range s.byEmail[strings.ToLower(req.Email)] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.DateOfBirth != "" {
+		for id := range s.byBirthDate[req.DateOfBirth] {
+			matched[id] = struct{}{}
+		}
+	}
+	if req.Country != "" {
+		for id := range s.byCountry[req.Country] {
+			matched[id] = struct{}{}
+		}
+	}
+
+	ids := make([]uint32, 0, len(matched))
+	for id := range matched {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func addToIndex[K comparable](idx map[K]map[uint32]struct{}, key K, id uint32) {
+	if idx[key] == nil {
+		idx[key] = make(map[uint32]struct{})
+	}
+	idx[key][id] = struct{}{}
+}
+
+func removeFromIndex[K comparable](idx map[K]map[uint32]struct{}, key K, id uint32) {
+	delete(idx[key], id)
+	if len(idx[key]) == 0 {
+		delete(idx, key)
+	}
+}
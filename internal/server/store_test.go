@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchIndexes(t *testing.T) {
+	userServer := NewUserServer()
+
+	tests := []struct {
+		name        string
+		req         *pb.SearchUsersRequest
+		expectedIDs []uint32
+	}{
+		{"should match city case-insensitively", &pb.SearchUsersRequest{City: "la"}, []uint32{1, 3}},
+		{"should match phone", &pb.SearchUsersRequest{Phone: "9876543210"}, []uint32{2}},
+		{"should match marital status", &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus_SINGLE}, []uint32{2}},
+		{"should union criteria", &pb.SearchUsersRequest{City: "NY", Phone: "9827329211"}, []uint32{1, 2}},
+		{"should match nothing", &pb.SearchUsersRequest{City: "Boston"}, []uint32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedIDs, userServer.search(tt.req))
+		})
+	}
+}
+
+func TestSearchCriteria(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: &pb.User{Fname: "Carol", Lname: "Smith", City: "SF", Phone: "9123456789", Height: 5.4}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		req         *pb.SearchUsersRequest
+		expectedIDs []uint32
+	}{
+		{"should not match users on unset criteria", &pb.SearchUsersRequest{City: "la"}, []uint32{1, 3}},
+		{"should match unknown status only when asked", &pb.SearchUsersRequest{UnknownMaritalStatus: true}, []uint32{4}},
+		{"should return users matching any criterion in ID order", &pb.SearchUsersRequest{Lname: "smith", City: "NY", Phone: "9827329211"}, []uint32{1, 2, 4}},
+		{"should return a user matching several criteria once", &pb.SearchUsersRequest{City: "LA", IsMarried: pb.MaritalStatus_MARRIED}, []uint32{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.SearchUsers(context.Background(), tt.req)
+			assert.NoError(t, err)
+			ids := []uint32{}
+			for _, user := range resp.Users {
+				ids = append(ids, user.Id)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	userServer := NewUserServer()
+	stats := userServer.Stats()
+
+	assert.Equal(t, 3, stats.Users)
+	assert.Equal(t, 2, stats.CityIndexKeys)
+	assert.Equal(t, 3, stats.PhoneIndexKeys)
+	assert.Equal(t, 2, stats.MarriedIndexKeys)
+	assert.Equal(t, uint64(1), stats.LockAcquisitions)
+}
+
+func TestAllocateID(t *testing.T) {
+	userServer := NewUserServer()
+	_, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 3})
+	assert.NoError(t, err)
+	_, err = userServer.EraseUser(context.Background(), &pb.EraseUserRequest{Id: 2})
+	assert.NoError(t, err)
+
+	userServer.nextID = 1
+	id, err := userServer.allocateID()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4), id, "live, deleted and erased IDs should be skipped")
+	assert.Equal(t, uint32(5), userServer.nextID)
+
+	userServer.nextID = math.MaxUint32 - 1
+	id, err = userServer.allocateID()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(math.MaxUint32-1), id)
+	_, err = userServer.allocateID()
+	assert.ErrorIs(t, err, errors.ErrQuotaExceeded)
+	assert.Equal(t, uint32(math.MaxUint32), userServer.nextID, "nextID should not wrap")
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/tenant"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// Tenants serves the UserService for many tenants. Each tenant gets its own
+// UserServer, so IDs, indexes, history, watchers and quotas never mix:
+// GetUser(1) only ever returns user 1 of the caller's tenant. The tenant of a
+// call is read with tenant.FromContext.
+//
+// The default tenant always exists. Other tenants are created by the first
+// write adding users or attributes to them; other calls on a tenant that does
+// not exist yet see an empty store, so callers cannot grow the number of
+// tenants by reading, updating or deleting.
+type Tenants struct {
+	pb.UnimplementedUserServiceServer
+	newServer  func(tenant string) *UserServer
+	maxTenants int
+	// Answers reads of tenants that do not exist. Nothing is ever stored in
+	// it since writes create their tenant.
+	empty *UserServer
+
+	mu      sync.Mutex
+	servers map[string]*UserServer
+}
+
+// TenantsOption configures a Tenants.
+type TenantsOption func(*Tenants)
+
+// WithMaxTenants caps the number of tenants, the default tenant included.
+// Writes that would create a tenant beyond it fail with ErrQuotaExceeded.
+func WithMaxTenants(n int) TenantsOption {
+	return func(t *Tenants) { t.maxTenants = n }
+}
+
+// NewTenants returns a Tenants creating the server of each tenant with
+// newServer.
+func NewTenants(newServer func(tenant string) *UserServer, opts ...TenantsOption) *Tenants {
+	t := &Tenants{
+		newServer: newServer,
+		empty:     NewUserServer(WithoutSampleUsers()),
+		servers:   make(map[string]*UserServer),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.servers[tenant.Default] = newServer(tenant.Default)
+	return t
+}
+
+// Tenant returns the server of the named tenant, creating it if needed. It
+// fails with ErrQuotaExceeded when the tenant would be one too many.
+func (t *Tenants) Tenant(name string) (*UserServer, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, found := t.servers[name]
+	if !found {
+		if t.maxTenants > 0 && len(t.servers) >= t.maxTenants {
+			return nil, fmt.Errorf("%w: at most %d tenants, cannot create %s", errors.ErrQuotaExceeded, t.maxTenants, name)
+		}
+		s = t.newServer(name)
+		t.servers[name] = s
+	}
+	return s, nil
+}
+
+// lookup returns the server of the named tenant, or nil if it does not exist.
+func (t *Tenants) lookup(name string) *UserServer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.servers[name]
+}
+
+// Names returns the tenants served so far, sorted.
+func (t *Tenants) Names() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.servers))
+	for name := range t.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the statistics of every tenant added together.
+func (t *Tenants) Stats() Stats {
+	var total Stats
+	for _, name := range t.Names() {
+		s := t.lookup(name).Stats()
+		total.Users += s.Users
+		total.CityIndexKeys += s.CityIndexKeys
+		total.PhoneIndexKeys += s.PhoneIndexKeys
+		total.MarriedIndexKeys += s.MarriedIndexKeys
+		total.LnameIndexKeys += s.LnameIndexKeys
+		total.EmailIndexKeys += s.EmailIndexKeys
+		total.BirthIndexKeys += s.BirthIndexKeys
+		total.CountryIndexKeys += s.CountryIndexKeys
+		total.AttrIndexKeys += s.AttrIndexKeys
+		total.LockWait += s.LockWait
+		total.LockAcquisitions += s.LockAcquisitions
+		total.Tenants++
+	}
+	return total
+}
+
+// of returns the server of the caller's tenant for a write, creating the
+// tenant if needed.
+func (t *Tenants) of(ctx context.Context) (*UserServer, error) {
+	return t.Tenant(tenant.FromContext(ctx))
+}
+
+// reader returns the server of the caller's tenant for calls that cannot
+// store anything in a tenant that does not exist yet, such as reads, updates
+// and deletes, or an empty server when the tenant does not exist.
+func (t *Tenants) reader(ctx context.Context) *UserServer {
+	if s := t.lookup(tenant.FromContext(ctx)); s != nil {
+		return s
+	}
+	return t.empty
+}
+
+func (t *Tenants) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	return t.reader(ctx).GetUser(ctx, req)
+}
+
+func (t *Tenants) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	return t.reader(ctx).ListUsers(ctx, req)
+}
+
+func (t *Tenants) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	return t.reader(ctx).SearchUsers(ctx, req)
+}
+
+func (t *Tenants) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	s, err := t.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateUser(ctx, req)
+}
+
+func (t *Tenants) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	return t.reader(ctx).UpdateUser(ctx, req)
+}
+
+func (t *Tenants) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	return t.reader(ctx).DeleteUser(ctx, req)
+}
+
+func (t *Tenants) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	// A watch on the empty server would never see the tenant's first users
+	name := tenant.FromContext(stream.Context())
+	s := t.lookup(name)
+	if s == nil {
+		return fmt.Errorf("%w: tenant %s holds no users yet", errors.ErrUserNotFound, name)
+	}
+	return s.WatchUsers(req, stream)
+}
+
+func (t *Tenants) ImportUsers(stream pb.UserService_ImportUsersServer) error {
+	s, err := t.of(stream.Context())
+	if err != nil {
+		return err
+	}
+	return s.ImportUsers(stream)
+}
+
+func (t *Tenants) ExportUsers(req *pb.ExportUsersRequest, stream pb.UserService_ExportUsersServer) error {
+	return t.reader(stream.Context()).ExportUsers(req, stream)
+}
+
+func (t *Tenants) BatchCreateUsers(stream pb.UserService_BatchCreateUsersServer) error {
+	s, err := t.of(stream.Context())
+	if err != nil {
+		return err
+	}
+	return s.BatchCreateUsers(stream)
+}
+
+func (t *Tenants) GetUserHistory(ctx context.Context, req *pb.GetUserHistoryRequest) (*pb.GetUserHistoryResponse, error) {
+	return t.reader(ctx).GetUserHistory(ctx, req)
+}
+
+func (t *Tenants) ExportUserData(ctx context.Context, req *pb.ExportUserDataRequest) (*pb.ExportUserDataResponse, error) {
+	return t.reader(ctx).ExportUserData(ctx, req)
+}
+
+func (t *Tenants) EraseUser(ctx context.Context, req *pb.EraseUserRequest) (*pb.EraseUserResponse, error) {
+	return t.reader(ctx).EraseUser(ctx, req)
+}
+
+func (t *Tenants) RegisterAttribute(ctx context.Context, req *pb.RegisterAttributeRequest) (*pb.RegisterAttributeResponse, error) {
+	s, err := t.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.RegisterAttribute(ctx, req)
+}
+
+func (t *Tenants) ListAttributes(ctx context.Context, req *pb.ListAttributesRequest) (*pb.ListAttributesResponse, error) {
+	return t.reader(ctx).ListAttributes(ctx, req)
+}
+
+func (t *Tenants) DeleteAttribute(ctx context.Context, req *pb.DeleteAttributeRequest) (*pb.DeleteAttributeResponse, error) {
+	return t.reader(ctx).DeleteAttribute(ctx, req)
+}
+
+func (t *Tenants) FindUniqueViolations(ctx context.Context, req *pb.FindUniqueViolationsRequest) (*pb.FindUniqueViolationsResponse, error) {
+	return t.reader(ctx).FindUniqueViolations(ctx, req)
+}
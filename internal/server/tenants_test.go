@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/tenant"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func newTenants() *Tenants {
+	return NewTenants(func(name string) *UserServer {
+		opts := []Option{WithTenant(name), WithMaxUsers(5)}
+		if name != tenant.Default {
+			opts = append(opts, WithoutSampleUsers())
+		}
+		return NewUserServer(opts...)
+	})
+}
+
+func TestTenantsIsolation(t *testing.T) {
+	tenants := newTenants()
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+
+	resp, err := tenants.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Steve", resp.User.Fname, "the default tenant should hold the sample users")
+	_, err = tenants.GetUser(acme, &pb.GetUserRequest{Id: 1})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound, "other tenants should start empty")
+
+	carol, err := tenants.CreateUser(acme, &pb.CreateUserRequest{User: &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}})
+	assert.NoError(t, err)
+	dave, err := tenants.CreateUser(globex, &pb.CreateUserRequest{User: &pb.User{Fname: "Dave", City: "SF", Phone: "9123456789", Height: 5.9}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), carol.User.Id, "each tenant should number its own users")
+	assert.Equal(t, uint32(1), dave.User.Id)
+
+	got, err := tenants.GetUser(acme, &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Carol", got.User.Fname)
+	found, err := tenants.SearchUsers(globex, &pb.SearchUsersRequest{City: "SF"})
+	assert.NoError(t, err)
+	if assert.Len(t, found.Users, 1) {
+		assert.Equal(t, "Dave", found.Users[0].Fname)
+	}
+
+	_, err = tenants.DeleteUser(acme, &pb.DeleteUserRequest{Id: 1})
+	assert.NoError(t, err)
+	_, err = tenants.GetUser(globex, &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err, "deleting in one tenant should not affect another")
+
+	assert.Equal(t, []string{"acme", tenant.Default, "globex"}, tenants.Names())
+	stats := tenants.Stats()
+	assert.Equal(t, 3, stats.Tenants)
+	assert.Equal(t, 4, stats.Users)
+}
+
+type tenantWatchStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantWatchStream) Context() context.Context { return s.ctx }
+
+func (s *tenantWatchStream) Send(*pb.UserEvent) error { return nil }
+
+func TestTenantsUnknown(t *testing.T) {
+	tenants := newTenants()
+	ghost := tenant.NewContext(context.Background(), "ghost")
+
+	resp, err := tenants.GetUser(ghost, &pb.GetUserRequest{Id: 1})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	assert.Equal(t, uint32(404), resp.StatusCode)
+	_, err = tenants.UpdateUser(ghost, &pb.UpdateUserRequest{User: &pb.User{Id: 1, City: "SF"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"city"}}})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = tenants.DeleteUser(ghost, &pb.DeleteUserRequest{Id: 1})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	list, err := tenants.ListAttributes(ghost, &pb.ListAttributesRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Definitions)
+	assert.ErrorIs(t, tenants.WatchUsers(&pb.WatchUsersRequest{}, &tenantWatchStream{ctx: ghost}), errors.ErrUserNotFound)
+	assert.Equal(t, []string{tenant.Default}, tenants.Names(), "reads, updates and deletes should not create tenants")
+}
+
+func TestTenantsLimit(t *testing.T) {
+	tenants := NewTenants(func(name string) *UserServer {
+		return NewUserServer(WithTenant(name), WithoutSampleUsers())
+	}, WithMaxTenants(2))
+	valid := &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}
+
+	_, err := tenants.CreateUser(tenant.NewContext(context.Background(), "acme"), &pb.CreateUserRequest{User: valid})
+	assert.NoError(t, err)
+	_, err = tenants.CreateUser(tenant.NewContext(context.Background(), "globex"), &pb.CreateUserRequest{User: valid})
+	assert.ErrorIs(t, err, errors.ErrQuotaExceeded)
+	_, err = tenants.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid})
+	assert.NoError(t, err, "existing tenants should still accept writes")
+	assert.Equal(t, []string{"acme", tenant.Default}, tenants.Names())
+}
+
+func TestTenantsQuota(t *testing.T) {
+	valid := func() *pb.User {
+		return &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}
+	}
+
+	t.Run("should limit created users", func(t *testing.T) {
+		userServer := NewUserServer(WithMaxUsers(4))
+		resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid()})
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(201), resp.StatusCode)
+
+		resp, err = userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid()})
+		assert.ErrorIs(t, err, errors.ErrQuotaExceeded)
+		assert.Equal(t, uint32(429), resp.StatusCode)
+	})
+
+	t.Run("should free quota on delete", func(t *testing.T) {
+		userServer := NewUserServer(WithMaxUsers(3))
+		_, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 1})
+		assert.NoError(t, err)
+		_, err = userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: valid()})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should report batch users over quota", func(t *testing.T) {
+		userServer := NewUserServer(WithMaxUsers(5))
+		stream := &batchStream{reqs: []*pb.BatchCreateUsersRequest{{Users: []*pb.User{valid(), valid(), valid()}}}}
+		assert.NoError(t, userServer.BatchCreateUsers(stream))
+		results := stream.resps[0].Results
+		assert.Equal(t, uint32(4), results[0].GetId())
+		assert.Equal(t, uint32(5), results[1].GetId())
+		assert.Contains(t, results[2].GetError(), errors.ErrQuotaExceeded.Error())
+	})
+
+	t.Run("should fail imports over quota", func(t *testing.T) {
+		userServer := NewUserServer(WithMaxUsers(4))
+		stream := &importStream{reqs: []*pb.ImportUsersRequest{
+			{Mode: pb.ImportUsersRequest_UPSERT, User: &pb.User{Id: 1, Fname: "Steve", City: "SF", Phone: "9827329211", Height: 5.8}},
+			{Mode: pb.ImportUsersRequest_UPSERT, User: &pb.User{Id: 9, Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4}},
+			{Mode: pb.ImportUsersRequest_UPSERT, User: valid()},
+		}}
+		assert.NoError(t, userServer.ImportUsers(stream))
+		assert.Equal(t, uint32(1), stream.resp.Updated, "updates should not count against the quota")
+		assert.Equal(t, uint32(1), stream.resp.Created)
+		assert.Equal(t, uint32(1), stream.resp.Failed)
+	})
+}
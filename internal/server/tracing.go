@@ -0,0 +1,21 @@
+package server
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracer creates the child spans for validation and store operations.
+var tracer = otel.Tracer("user-service-module/internal/server")
+
+func userIDsAttribute(ids ...uint32) attribute.KeyValue {
+	values := make([]int64, len(ids))
+	for i, id := range ids {
+		values[i] = int64(id)
+	}
+	return attribute.Int64Slice("user.ids", values)
+}
+
+func resultCountAttribute(n int) attribute.KeyValue {
+	return attribute.Int("user.result_count", n)
+}
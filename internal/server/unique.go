@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// uniqueField is a user field that can be made unique, checked through the
+// secondary index holding its values.
+type uniqueField struct {
+	index func(s *UserServer) map[string]map[uint32]struct{}
+	// key returns the value of the field as indexed
+	key func(u *pb.User) string
+}
+
+var uniqueFields = map[string]uniqueField{
+	"phone": {
+		index: func(s *UserServer) map[string]map[uint32]struct{} { return s.byPhone },
+		key:   func(u *pb.User) string { return u.Phone },
+	},
+	"email": {
+		index: func(s *UserServer) map[string]map[uint32]struct{} { return s.byEmail },
+		key:   func(u *pb.User) string { return strings.ToLower(u.Email) },
+	},
+}
+
+// UniqueFields returns the user fields that can be made unique, sorted.
+func UniqueFields() []string {
+	fields := make([]string, 0, len(uniqueFields))
+	for name := range uniqueFields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// checkUnique reports the first unique field of user whose value a user
+// other than existing already holds. existing is nil for new users, whatever
+// ID the request carried. Values unchanged from existing are not checked, so
+// users stored before a constraint applied can still be updated. Unset values
+// never conflict. The caller must hold mu.
+func (s *UserServer) checkUnique(user, existing *pb.User) error {
+	var self uint32
+	if existing != nil {
+		self = existing.Id
+	}
+	for _, name := range s.unique {
+		field := uniqueFields[name]
+		value := field.key(user)
+		if value == "" || existing != nil && field.key(existing) == value {
+			continue
+		}
+		var conflict uint32
+		for id := range field.index(s)[value] {
+			if id != self && (conflict == 0 || id < conflict) {
+				conflict = id
+			}
+		}
+		if conflict != 0 {
+			return fmt.Errorf("%w: %s is taken by user %d", errors.ErrUniqueViolation, name, conflict)
+		}
+	}
+	return nil
+}
+
+// FindUniqueViolations returns the values of the requested fields that more
+// than one user holds, such as duplicates in seeded or imported data, so they
+// can be resolved before a constraint is relied on.
+func (s *UserServer) FindUniqueViolations(ctx context.Context, req *pb.FindUniqueViolationsRequest) (*pb.FindUniqueViolationsResponse, error) {
+	_, validation := tracer.Start(ctx, "validate")
+	fields := req.Fields
+	if len(fields) == 0 {
+		fields = UniqueFields()
+	}
+	var invalidFields []string
+	for _, name := range fields {
+		if _, found := uniqueFields[name]; !found {
+			invalidFields = append(invalidFields, name)
+		}
+	}
+	validation.End()
+	if len(invalidFields) > 0 {
+		return &pb.FindUniqueViolationsResponse{
+			StatusCode: http.StatusBadRequest,
+		}, fmt.Errorf("%w: %v", errors.ErrInvalidFields, strings.Join(invalidFields, ", "))
+	}
+
+	_, lookup := tracer.Start(ctx, "store.find_unique_violations")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	violations := []*pb.UniqueViolation{}
+	checked := make(map[string]bool, len(fields))
+	for _, name := range fields {
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+		for value, holders := range uniqueFields[name].index(s) {
+			if len(holders) < 2 {
+				continue
+			}
+			ids := make([]uint32, 0, len(holders))
+			for id := range holders {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			violations = append(violations, &pb.UniqueViolation{Field: name, Value: value, Ids: ids})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+		return violations[i].Value < violations[j].Value
+	})
+	return &pb.FindUniqueViolationsResponse{
+		StatusCode: http.StatusOK,
+		Violations: violations,
+	}, nil
+}
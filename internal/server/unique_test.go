@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestUniqueFields(t *testing.T) {
+	newUser := func(phone, email string) *pb.User {
+		return &pb.User{Fname: "Carol", City: "SF", Phone: phone, Height: 5.4, Email: email}
+	}
+
+	t.Run("should reject a taken phone on create", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9876543210", "")})
+		assert.ErrorIs(t, err, errors.ErrUniqueViolation)
+		assert.EqualError(t, err, "error: unique constraint violated: phone is taken by user 2")
+		assert.Equal(t, uint32(409), resp.StatusCode)
+		assert.Equal(t, 3, userServer.Stats().Users)
+	})
+
+	t.Run("should reject a taken phone on create despite a spoofed ID", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		user := newUser("9876543210", "")
+		user.Id = 2
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: user})
+		assert.EqualError(t, err, "error: unique constraint violated: phone is taken by user 2")
+		assert.Equal(t, 3, userServer.Stats().Users)
+	})
+
+	t.Run("should reject a taken phone in a batch despite a spoofed ID", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		user := newUser("9876543210", "")
+		user.Id = 2
+		stream := &batchStream{reqs: []*pb.BatchCreateUsersRequest{{Users: []*pb.User{user}}}}
+		assert.NoError(t, userServer.BatchCreateUsers(stream))
+		assert.Equal(t, "error: unique constraint violated: phone is taken by user 2", stream.resps[0].Results[0].GetError())
+		assert.Equal(t, 3, userServer.Stats().Users)
+	})
+
+	t.Run("should allow shared values without constraints", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("height"))
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9876543210", "")})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should compare emails case-insensitively and ignore unset ones", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("email", "phone"))
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9123456789", "carol@example.com")})
+		assert.NoError(t, err)
+		_, err = userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9123456780", "Carol@Example.com")})
+		assert.EqualError(t, err, "error: unique constraint violated: email is taken by user 4")
+		_, err = userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9123456780", "")})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject a taken phone on update", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		resp, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+			User:       &pb.User{Id: 1, Phone: "9876543210"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"phone"}},
+		})
+		assert.ErrorIs(t, err, errors.ErrUniqueViolation)
+		assert.Equal(t, uint32(409), resp.StatusCode)
+
+		_, err = userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+			User:       &pb.User{Id: 2, Phone: "9876543210"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"phone"}},
+		})
+		assert.NoError(t, err, "a user keeping its own phone should not conflict")
+	})
+
+	t.Run("should allow updates leaving an existing violation unchanged", func(t *testing.T) {
+		userServer := NewUserServer()
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: newUser("9876543210", "")})
+		assert.NoError(t, err)
+		userServer.unique = []string{"phone"}
+
+		_, err = userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+			User:       &pb.User{Id: 4, City: "Boston"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"city"}},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject duplicates within a batch", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		stream := &batchStream{reqs: []*pb.BatchCreateUsersRequest{{Users: []*pb.User{
+			newUser("9123456789", ""),
+			newUser("9123456789", ""),
+		}}}}
+		assert.NoError(t, userServer.BatchCreateUsers(stream))
+		results := stream.resps[0].Results
+		assert.Equal(t, uint32(4), results[0].GetId())
+		assert.Equal(t, "error: unique constraint violated: phone is taken by user 4", results[1].GetError())
+	})
+
+	t.Run("should report imported rows with a taken phone", func(t *testing.T) {
+		userServer := NewUserServer(WithUniqueFields("phone"))
+		stream := &importStream{reqs: []*pb.ImportUsersRequest{
+			{Line: 1, Mode: pb.ImportUsersRequest_UPSERT, User: &pb.User{Id: 1, Fname: "Steve", City: "Boston", Phone: "9827329211", Height: 5.8}},
+			{Line: 2, User: newUser("9876543210", "")},
+		}}
+		assert.NoError(t, userServer.ImportUsers(stream))
+		assert.Equal(t, uint32(1), stream.resp.Updated)
+		assert.Equal(t, []*pb.ImportError{{Line: 2, Error: "error: unique constraint violated: phone is taken by user 2"}}, stream.resp.Errors)
+	})
+}
+
+func TestFindUniqueViolations(t *testing.T) {
+	userServer := NewUserServer()
+	for _, u := range []*pb.User{
+		{Fname: "Carol", City: "SF", Phone: "9876543210", Height: 5.4, Email: "carol@example.com"},
+		{Fname: "Dave", City: "SF", Phone: "9876543210", Height: 5.9, Email: "CAROL@example.com"},
+	} {
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: u})
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name         string
+		fields       []string
+		expected     []*pb.UniqueViolation
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{
+			name:   "should check every field by default",
+			fields: nil,
+			expected: []*pb.UniqueViolation{
+				{Field: "email", Value: "carol@example.com", Ids: []uint32{4, 5}},
+				{Field: "phone", Value: "9876543210", Ids: []uint32{2, 4, 5}},
+			},
+			expectedCode: 200,
+		},
+		{
+			name:         "should check the requested fields",
+			fields:       []string{"phone", "phone"},
+			expected:     []*pb.UniqueViolation{{Field: "phone", Value: "9876543210", Ids: []uint32{2, 4, 5}}},
+			expectedCode: 200,
+		},
+		{
+			name:         "should return error for fields that cannot be unique",
+			fields:       []string{"phone", "city"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.FindUniqueViolations(context.Background(), &pb.FindUniqueViolationsRequest{Fields: tt.fields})
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resp.Violations)
+		})
+	}
+}
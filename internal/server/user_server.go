@@ -2,47 +2,207 @@ package server
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"user-service-module/internal/errors"
+	"user-service-module/internal/tenant"
 	"user-service-module/internal/utils"
 	pb "user-service-module/proto/user/userpb"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserServer struct {
 	pb.UnimplementedUserServiceServer
 	users map[uint32]*pb.User
 	mu    sync.Mutex
+
+	// Next ID handed out by CreateUser, guarded by mu
+	nextID uint32
+
+	// Secondary indexes used by SearchUsers, guarded by mu
+	byCity    map[string]map[uint32]struct{}
+	byPhone   map[string]map[uint32]struct{}
+	byMarried map[pb.MaritalStatus]map[uint32]struct{}
+	// Optional fields are only indexed when set
+	byLname     map[string]map[uint32]struct{}
+	byEmail     map[string]map[uint32]struct{}
+	byBirthDate map[string]map[uint32]struct{}
+	byCountry   map[string]map[uint32]struct{}
+	// Values of indexed attributes, by key then value
+	byAttribute map[string]map[string]map[uint32]struct{}
+
+	// Registered attribute definitions by key, guarded by mu
+	attributes map[string]*pb.AttributeDefinition
+	// Fields no two users may share, checked in order
+	unique []string
+
+	lockWait         atomic.Int64
+	lockAcquisitions atomic.Uint64
+
+	watchMu  sync.Mutex
+	watchers map[*watcher]struct{}
+
+	// Revisions of every user ever stored, by ID, guarded by mu. Kept after
+	// the user is deleted.
+	history map[uint32][]*pb.UserRevision
+	now     func() time.Time
+
+	// Receipts of erased users, by ID, guarded by mu
+	erasures map[uint32]*pb.ErasureReceipt
+	// Key signing data export archives, and the source of the audit records
+	// they include
+	archiveKey   ed25519.PrivateKey
+	auditRecords func(userID uint32) ([]json.RawMessage, error)
+	// Drops the cached responses holding an erased user
+	forgetResponses func(userID uint32) int
+
+	// Tenant served, and the most users it may hold, unlimited when 0
+	tenant   string
+	maxUsers int
+	noSample bool
+}
+
+// Option configures a UserServer.
+type Option func(*UserServer)
+
+// WithArchiveKey sets the key signing the archives returned by
+// ExportUserData. A key is generated when none is given.
+func WithArchiveKey(key ed25519.PrivateKey) Option {
+	return func(s *UserServer) { s.archiveKey = key }
 }
 
-func NewUserServer() *UserServer {
+// WithAuditRecords includes the audit records returned by find for the user
+// in the archives returned by ExportUserData.
+func WithAuditRecords(find func(userID uint32) ([]json.RawMessage, error)) Option {
+	return func(s *UserServer) { s.auditRecords = find }
+}
+
+// WithResponseCache has EraseUser drop the responses forget holds for the
+// erased user, such as those kept to replay retried writes, and count them in
+// the receipt.
+func WithResponseCache(forget func(userID uint32) int) Option {
+	return func(s *UserServer) { s.forgetResponses = forget }
+}
+
+// WithTenant names the tenant whose users the server holds. It defaults to
+// tenant.Default.
+func WithTenant(name string) Option {
+	return func(s *UserServer) { s.tenant = name }
+}
+
+// WithMaxUsers caps the number of users the server holds. Creations beyond
+// it fail with ErrQuotaExceeded.
+func WithMaxUsers(n int) Option {
+	return func(s *UserServer) { s.maxUsers = n }
+}
+
+// WithClock sets the source of the times recorded on users and their
+// history. It defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *UserServer) { s.now = now }
+}
+
+// WithUniqueFields keeps writes from giving a user the value another user
+// already holds in any of fields, failing them with ErrUniqueViolation.
+// Fields not listed by UniqueFields are ignored.
+func WithUniqueFields(fields ...string) Option {
+	return func(s *UserServer) {
+		for _, name := range fields {
+			if _, found := uniqueFields[name]; found && !slices.Contains(s.unique, name) {
+				s.unique = append(s.unique, name)
+			}
+		}
+	}
+}
+
+// WithoutSampleUsers starts the server empty.
+func WithoutSampleUsers() Option {
+	return func(s *UserServer) { s.noSample = true }
+}
+
+func NewUserServer(opts ...Option) *UserServer {
+	s := &UserServer{
+		users:       make(map[uint32]*pb.User),
+		byCity:      make(map[string]map[uint32]struct{}),
+		byPhone:     make(map[string]map[uint32]struct{}),
+		byMarried:   make(map[pb.MaritalStatus]map[uint32]struct{}),
+		byLname:     make(map[string]map[uint32]struct{}),
+		byEmail:     make(map[string]map[uint32]struct{}),
+		byBirthDate: make(map[string]map[uint32]struct{}),
+		byCountry:   make(map[string]map[uint32]struct{}),
+		byAttribute: make(map[string]map[string]map[uint32]struct{}),
+		attributes:  make(map[string]*pb.AttributeDefinition),
+		watchers:    make(map[*watcher]struct{}),
+		history:     make(map[uint32][]*pb.UserRevision),
+		now:         time.Now,
+		erasures:    make(map[uint32]*pb.ErasureReceipt),
+		nextID:      1,
+		tenant:      tenant.Default,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.archiveKey == nil {
+		_, s.archiveKey, _ = ed25519.GenerateKey(nil)
+	}
+	if s.noSample {
+		return s
+	}
+
 	// Initialize the map with sample data
 	// Using a map for faster lookups
-	userMap := map[uint32]*pb.User{
-		1: {Id: 1, Fname: "Steve", City: "LA", Phone: "9827329211", Height: 5.8, IsMarried: pb.MaritalStatus_MARRIED},
-		2: {Id: 2, Fname: "Bob", City: "NY", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE},
-		3: {Id: 3, Fname: "Alice", City: "LA", Phone: "9876545876", Height: 5.5, IsMarried: pb.MaritalStatus_MARRIED},
+	s.users = map[uint32]*pb.User{
+		1: {Id: 1, Fname: "Steve", City: "LA", Phone: "9827329211", Height: 5.8, IsMarried: pb.MaritalStatus_MARRIED, Version: 1},
+		2: {Id: 2, Fname: "Bob", City: "NY", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE, Version: 1},
+		3: {Id: 3, Fname: "Alice", City: "LA", Phone: "9876545876", Height: 5.5, IsMarried: pb.MaritalStatus_MARRIED, Version: 1},
 	}
-
-	return &UserServer{
-		users: userMap,
+	created := timestamppb.New(s.now())
+	for id, user := range s.users {
+		user.CreateTime, user.UpdateTime = created, created
+		s.index(user)
+		s.nextID = max(s.nextID, id+1)
+		s.history[id] = []*pb.UserRevision{{
+			Type:    pb.UserEvent_CREATED,
+			Time:    timestamppb.New(s.now()),
+			Actor:   seedActor,
+			Changes: diff(nil, user),
+			User:    user,
+		}}
 	}
+	return s
 }
 
 func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(userIDsAttribute(req.Id))
 
-	if !utils.IsIDValid(req.Id) {
+	_, validation := tracer.Start(ctx, "validate")
+	isValid := utils.IsIDValid(req.Id)
+	validation.End()
+	if !isValid {
 		return &pb.GetUserResponse{
 			StatusCode: http.StatusBadRequest,
 			User:       &pb.User{},
 		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.Id)
 	}
+
+	_, lookup := tracer.Start(ctx, "store.get")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
 	if user, found := s.users[req.Id]; found {
+		span.SetAttributes(resultCountAttribute(1))
 		return &pb.GetUserResponse{
 			StatusCode: http.StatusOK,
 			User:       user,
@@ -56,11 +216,13 @@ func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.G
 }
 
 func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(userIDsAttribute(req.Ids...))
 
 	users := []*pb.User{}
+	_, validation := tracer.Start(ctx, "validate")
 	invalidIDs := utils.GetInvalidIDs(req.Ids)
+	validation.End()
 	if len(invalidIDs) > 0 {
 		return &pb.ListUsersResponse{
 			StatusCode: http.StatusBadRequest,
@@ -68,6 +230,11 @@ func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*
 		}, fmt.Errorf("%w: %v", errors.ErrInvalidID, invalidIDs)
 	}
 
+	_, lookup := tracer.Start(ctx, "store.list")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
 	usersNotFound := []uint32{}
 	for _, id := range req.Ids {
 		if user, found := s.users[id]; found {
@@ -84,6 +251,7 @@ func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*
 		}, fmt.Errorf("%w: %v", errors.ErrUserNotFound, usersNotFound)
 	}
 
+	span.SetAttributes(resultCountAttribute(len(users)))
 	return &pb.ListUsersResponse{
 		StatusCode: http.StatusOK,
 		Users:      users,
@@ -91,23 +259,32 @@ func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*
 }
 
 func (s *UserServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	span := trace.SpanFromContext(ctx)
 
 	users := []*pb.User{}
-    if isReqInvalid, err := utils.ValidateSearchRequest(req.City, req.Phone, req.IsMarried); !isReqInvalid {
+	_, validation := tracer.Start(ctx, "validate")
+	isReqInvalid, err := utils.ValidateSearchRequest(req)
+	validation.End()
+    if !isReqInvalid {
         return &pb.SearchUsersResponse{
             StatusCode: http.StatusBadRequest,
             Users:      users,
         }, err
     }
 
-	for _, user := range s.users {
-		if (strings.EqualFold(user.City, req.City)) ||
-			(user.Phone == req.Phone) ||
-			(user.IsMarried == req.IsMarried) {
-				users = append(users, user)
-		}
+	_, lookup := tracer.Start(ctx, "store.search")
+	defer lookup.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSearchAttributes(req); err != nil {
+		return &pb.SearchUsersResponse{
+			StatusCode: http.StatusBadRequest,
+			Users:      users,
+		}, err
+	}
+	for _, id := range s.search(req) {
+		users = append(users, s.users[id])
 	}
 
     if len(users) == 0 {
@@ -117,8 +294,173 @@ func (s *UserServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest
         }, fmt.Errorf("%w", errors.ErrUserNotFound)
     }
 
+	span.SetAttributes(resultCountAttribute(len(users)))
 	return &pb.SearchUsersResponse{
 		StatusCode: http.StatusOK,
 		Users:      users,
 	}, nil
 }
+
+// Stored users are never modified in place: writes replace them with a new
+// value, so responses already handed out stay consistent.
+
+func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	_, validation := tracer.Start(ctx, "validate")
+	err := utils.ValidateUser(req.User)
+	validation.End()
+	if err != nil {
+		return &pb.CreateUserResponse{
+			StatusCode: http.StatusBadRequest,
+			User:       &pb.User{},
+		}, err
+	}
+
+	_, write := tracer.Start(ctx, "store.create")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkAttributes(req.User); err != nil {
+		return &pb.CreateUserResponse{
+			StatusCode: http.StatusBadRequest,
+			User:       &pb.User{},
+		}, err
+	}
+	if err := s.checkUnique(req.User, nil); err != nil {
+		return &pb.CreateUserResponse{
+			StatusCode: http.StatusConflict,
+			User:       &pb.User{},
+		}, err
+	}
+	if err := s.checkQuota(1); err != nil {
+		return &pb.CreateUserResponse{
+			StatusCode: http.StatusTooManyRequests,
+			User:       &pb.User{},
+		}, err
+	}
+	id, err := s.allocateID()
+	if err != nil {
+		return &pb.CreateUserResponse{
+			StatusCode: http.StatusTooManyRequests,
+			User:       &pb.User{},
+		}, err
+	}
+	user := proto.Clone(req.User).(*pb.User)
+	user.Id = id
+	user.Version = 1
+	s.users[user.Id] = user
+	s.index(user)
+	s.changed(actorOf(ctx), pb.UserEvent_CREATED, nil, user)
+
+	span.SetAttributes(userIDsAttribute(user.Id))
+	return &pb.CreateUserResponse{
+		StatusCode: http.StatusCreated,
+		User:       user,
+	}, nil
+}
+
+func (s *UserServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	if req.User == nil {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusBadRequest,
+			User:       &pb.User{},
+		}, fmt.Errorf("%w: %v", errors.ErrInvalidFields, "user must be provided")
+	}
+	span.SetAttributes(userIDsAttribute(req.User.Id))
+
+	if !utils.IsIDValid(req.User.Id) {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusBadRequest,
+			User:       &pb.User{},
+		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.User.Id)
+	}
+
+	_, write := tracer.Start(ctx, "store.update")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	existing, found := s.users[req.User.Id]
+	if !found {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusNotFound,
+			User:       &pb.User{},
+		}, fmt.Errorf("%w: %d", errors.ErrUserNotFound, req.User.Id)
+	}
+	if err := checkVersion(existing, req.ExpectedVersion); err != nil {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusPreconditionFailed,
+			User:       &pb.User{},
+		}, err
+	}
+
+	_, validation := tracer.Start(ctx, "validate")
+	updated, err := applyUpdate(existing, req.User, req.UpdateMask.GetPaths())
+	if err == nil {
+		err = utils.ValidateUser(updated)
+	}
+	if err == nil {
+		err = s.checkAttributes(updated)
+	}
+	validation.End()
+	if err != nil {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusBadRequest,
+			User:       &pb.User{},
+		}, err
+	}
+	if err := s.checkUnique(updated, existing); err != nil {
+		return &pb.UpdateUserResponse{
+			StatusCode: http.StatusConflict,
+			User:       &pb.User{},
+		}, err
+	}
+
+	s.unindex(existing)
+	s.users[updated.Id] = updated
+	s.index(updated)
+	s.changed(actorOf(ctx), pb.UserEvent_UPDATED, existing, updated)
+
+	return &pb.UpdateUserResponse{
+		StatusCode: http.StatusOK,
+		User:       updated,
+	}, nil
+}
+
+func (s *UserServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	trace.SpanFromContext(ctx).SetAttributes(userIDsAttribute(req.Id))
+
+	if !utils.IsIDValid(req.Id) {
+		return &pb.DeleteUserResponse{
+			StatusCode: http.StatusBadRequest,
+		}, fmt.Errorf("%w: %d", errors.ErrInvalidID, req.Id)
+	}
+
+	_, write := tracer.Start(ctx, "store.delete")
+	defer write.End()
+	s.lock()
+	defer s.mu.Unlock()
+
+	user, found := s.users[req.Id]
+	if !found {
+		return &pb.DeleteUserResponse{
+			StatusCode: http.StatusNotFound,
+		}, fmt.Errorf("%w: %d", errors.ErrUserNotFound, req.Id)
+	}
+	if err := checkVersion(user, req.ExpectedVersion); err != nil {
+		return &pb.DeleteUserResponse{
+			StatusCode: http.StatusPreconditionFailed,
+		}, err
+	}
+
+	delete(s.users, req.Id)
+	s.unindex(user)
+	s.changed(actorOf(ctx), pb.UserEvent_DELETED, user, nil)
+
+	return &pb.DeleteUserResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
@@ -5,13 +5,24 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"user-service-module/internal/errors"
 	pb "user-service-module/proto/user/userpb"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// withoutTimes returns a copy of user without the times set by the server.
+func withoutTimes(user *pb.User) *pb.User {
+	user = proto.Clone(user).(*pb.User)
+	user.CreateTime, user.UpdateTime = nil, nil
+	return user
+}
+
 func TestGetUser(t *testing.T) {
 	userServer := NewUserServer()
 
@@ -32,6 +43,7 @@ func TestGetUser(t *testing.T) {
 				Phone:   "9827329211",
 				Height:  5.8,
 				IsMarried: pb.MaritalStatus_MARRIED,
+				Version: 1,
 			},
 			expectedCode: 200,
 			expectedErr:  nil,
@@ -56,7 +68,7 @@ func TestGetUser(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := userServer.GetUser(context.Background(), &pb.GetUserRequest{Id: tt.id})
 			assert.Equal(t, tt.expectedCode, resp.StatusCode)
-			assert.Equal(t, tt.expectedUser, resp.User)
+			assert.True(t, proto.Equal(tt.expectedUser, withoutTimes(resp.User)), "got %v", resp.User)
 			if tt.expectedErr != nil {
 				assert.ErrorIs(t, err, tt.expectedErr)
 			} else {
@@ -89,6 +101,7 @@ func TestListUsers(t *testing.T) {
 					Phone:   "9827329211",
 					Height:  5.8,
 					IsMarried: pb.MaritalStatus_MARRIED,
+					Version: 1,
 				},
 				{
 					Id:      2,
@@ -97,6 +110,7 @@ func TestListUsers(t *testing.T) {
 					Phone:   "9876543210",
 					Height:  6.1,
 					IsMarried: pb.MaritalStatus_SINGLE,
+					Version: 1,
 				},
 			},
 			expectedCode: 200,
@@ -125,7 +139,11 @@ func TestListUsers(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := userServer.ListUsers(context.Background(), &pb.ListUsersRequest{Ids: tt.ids})
 			assert.Equal(t, tt.expectedCode, resp.StatusCode)
-			assert.Equal(t, tt.expectedUsers, resp.Users)
+			if assert.Len(t, resp.Users, len(tt.expectedUsers)) {
+				for i := range tt.expectedUsers {
+					assert.True(t, proto.Equal(tt.expectedUsers[i], withoutTimes(resp.Users[i])), "got %v", resp.Users[i])
+				}
+			}
             fmt.Printf("Error: %v\n", err)
             fmt.Printf("Expected Error: %v\n", tt.expectedErr)
             fmt.Printf("Invalid IDs: %v\n", tt.invalidIDs)
@@ -257,3 +275,286 @@ func TestSearchUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchUsersByProfile(t *testing.T) {
+	userServer := NewUserServer()
+	carol := &pb.User{
+		Fname: "Carol", Lname: "Smith", Email: "Carol@Example.com", DateOfBirth: "1990-02-28",
+		City: "SF", Phone: "9123456789", Height: 5.4,
+		Address: &pb.Address{Street: "1 Market St", Region: "California", PostalCode: "94105", Country: "US"},
+	}
+	created, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: carol})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  *pb.SearchUsersRequest
+	}{
+		{"should find users by last name ignoring case", &pb.SearchUsersRequest{Lname: "smith"}},
+		{"should find users by email ignoring case", &pb.SearchUsersRequest{Email: "carol@example.com"}},
+		{"should find users by date of birth", &pb.SearchUsersRequest{DateOfBirth: "1990-02-28"}},
+		{"should find users by country", &pb.SearchUsersRequest{Country: "US"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.SearchUsers(context.Background(), tt.req)
+			assert.NoError(t, err)
+			if assert.Len(t, resp.Users, 1) {
+				assert.Equal(t, created.User.Id, resp.Users[0].Id)
+			}
+		})
+	}
+
+	t.Run("should unindex replaced values", func(t *testing.T) {
+		update := &pb.UpdateUserRequest{User: &pb.User{Id: created.User.Id, Address: &pb.Address{Country: "CA"}}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"address"}}}
+		_, err := userServer.UpdateUser(context.Background(), update)
+		assert.NoError(t, err)
+		_, err = userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{Country: "US"})
+		assert.ErrorIs(t, err, errors.ErrUserNotFound)
+		_, err = userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{Country: "CA"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestSearchUsersByMaritalStatus(t *testing.T) {
+	userServer := NewUserServer()
+	for _, u := range []*pb.User{
+		{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4, IsMarried: pb.MaritalStatus_DIVORCED},
+		{Fname: "Dave", City: "SF", Phone: "9123456780", Height: 5.9},
+	} {
+		_, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: u})
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *pb.SearchUsersRequest
+		expected []uint32
+	}{
+		{"should find users by an original status", &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus_MARRIED}, []uint32{1, 3}},
+		{"should find users by a new status", &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus_DIVORCED}, []uint32{4}},
+		{"should find users with an unknown status", &pb.SearchUsersRequest{UnknownMaritalStatus: true}, []uint32{5}},
+		{"should match either status", &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus_DIVORCED, UnknownMaritalStatus: true}, []uint32{4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.SearchUsers(context.Background(), tt.req)
+			assert.NoError(t, err)
+			var ids []uint32
+			for _, u := range resp.Users {
+				ids = append(ids, u.Id)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestUserTimes(t *testing.T) {
+	userServer := NewUserServer()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	userServer.now = func() time.Time { return created }
+
+	user := &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4, CreateTime: timestamppb.New(updated)}
+	resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: user})
+	assert.NoError(t, err)
+	assert.Equal(t, created, resp.User.CreateTime.AsTime(), "the creation time should be set by the server")
+	assert.Equal(t, created, resp.User.UpdateTime.AsTime())
+
+	userServer.now = func() time.Time { return updated }
+	resp.User.City = "Boston"
+	got, err := userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{User: resp.User})
+	assert.NoError(t, err)
+	assert.Equal(t, created, got.User.CreateTime.AsTime(), "updates should keep the creation time")
+	assert.Equal(t, updated, got.User.UpdateTime.AsTime())
+
+	_, err = userServer.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		User:       &pb.User{Id: resp.User.Id, CreateTime: timestamppb.New(updated)},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"createTime"}},
+	})
+	assert.ErrorIs(t, err, errors.ErrInvalidFields)
+}
+
+func TestCreateUser(t *testing.T) {
+	userServer := NewUserServer()
+
+	tests := []struct {
+		name         string
+		user         *pb.User
+		expectedID   uint32
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{
+			name:         "should create user with the next ID",
+			user:         &pb.User{Id: 99, Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4, IsMarried: pb.MaritalStatus_SINGLE},
+			expectedID:   4,
+			expectedCode: 201,
+		},
+		{
+			name:         "should return error for invalid user",
+			user:         &pb.User{Fname: "Carol", City: "SF", Phone: "012"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should return error for missing user",
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{User: tt.user})
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedID, resp.User.Id)
+
+			got, err := userServer.GetUser(context.Background(), &pb.GetUserRequest{Id: tt.expectedID})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.user.Fname, got.User.Fname)
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         *pb.User
+		paths        []string
+		version      uint32
+		expectedUser *pb.User
+		expectedCode uint32
+		expectedErr  error
+	}{
+		{
+			name:         "should update masked fields only",
+			user:         &pb.User{Id: 2, City: "Boston", Fname: "ignored"},
+			paths:        []string{"city"},
+			expectedUser: &pb.User{Id: 2, Fname: "Bob", City: "Boston", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE, Version: 2},
+			expectedCode: 200,
+		},
+		{
+			name:         "should replace all fields without a mask",
+			user:         &pb.User{Id: 2, Fname: "Robert", City: "NY", Phone: "9876543211", Height: 6, IsMarried: pb.MaritalStatus_MARRIED, Version: 7},
+			expectedUser: &pb.User{Id: 2, Fname: "Robert", City: "NY", Phone: "9876543211", Height: 6, IsMarried: pb.MaritalStatus_MARRIED, Version: 2},
+			expectedCode: 200,
+		},
+		{
+			name:         "should update nested address fields",
+			user:         &pb.User{Id: 2, Address: &pb.Address{Country: "US"}},
+			paths:        []string{"address.country"},
+			expectedUser: &pb.User{Id: 2, Fname: "Bob", City: "NY", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE, Address: &pb.Address{Country: "US"}, Version: 2},
+			expectedCode: 200,
+		},
+		{
+			name:         "should return error for a path below a scalar",
+			user:         &pb.User{Id: 2, City: "Boston"},
+			paths:        []string{"city.name"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should update at the expected version",
+			user:         &pb.User{Id: 2, City: "Boston"},
+			paths:        []string{"city"},
+			version:      1,
+			expectedUser: &pb.User{Id: 2, Fname: "Bob", City: "Boston", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE, Version: 2},
+			expectedCode: 200,
+		},
+		{
+			name:         "should return error for stale version",
+			user:         &pb.User{Id: 2, City: "Boston"},
+			paths:        []string{"city"},
+			version:      2,
+			expectedCode: 412,
+			expectedErr:  errors.ErrVersionMismatch,
+		},
+		{
+			name:         "should return error for version in mask",
+			user:         &pb.User{Id: 2, Version: 5},
+			paths:        []string{"version"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should return error for invalid update",
+			user:         &pb.User{Id: 2, Phone: "123"},
+			paths:        []string{"phone"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should return error for unknown mask field",
+			user:         &pb.User{Id: 2},
+			paths:        []string{"id"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidFields,
+		},
+		{
+			name:         "should return error for non-existent user",
+			user:         &pb.User{Id: 999, City: "Boston"},
+			paths:        []string{"city"},
+			expectedCode: 404,
+			expectedErr:  errors.ErrUserNotFound,
+		},
+		{
+			name:         "should return error for invalid ID",
+			user:         &pb.User{City: "Boston"},
+			paths:        []string{"city"},
+			expectedCode: 400,
+			expectedErr:  errors.ErrInvalidID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userServer := NewUserServer()
+			req := &pb.UpdateUserRequest{User: tt.user, UpdateMask: &fieldmaskpb.FieldMask{Paths: tt.paths}, ExpectedVersion: tt.version}
+			resp, err := userServer.UpdateUser(context.Background(), req)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tt.expectedUser, withoutTimes(resp.User)), "got %v", resp.User)
+
+			search, err := userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{City: tt.expectedUser.City})
+			assert.NoError(t, err)
+			assert.Contains(t, search.Users, resp.User, "indexes should follow the update")
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	userServer := NewUserServer()
+
+	resp, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 2, ExpectedVersion: 2})
+	assert.ErrorIs(t, err, errors.ErrVersionMismatch)
+	assert.Equal(t, uint32(412), resp.StatusCode)
+
+	resp, err = userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 2, ExpectedVersion: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(200), resp.StatusCode)
+
+	_, err = userServer.GetUser(context.Background(), &pb.GetUserRequest{Id: 2})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = userServer.SearchUsers(context.Background(), &pb.SearchUsersRequest{City: "NY"})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+
+	resp, err = userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 2})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	assert.Equal(t, uint32(404), resp.StatusCode)
+
+	resp, err = userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 0})
+	assert.ErrorIs(t, err, errors.ErrInvalidID)
+	assert.Equal(t, uint32(400), resp.StatusCode)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+
+	"user-service-module/internal/errors"
+	"user-service-module/internal/utils"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// watchBuffer is the number of events a watcher may fall behind before it is
+// disconnected, so a slow consumer never blocks writers.
+const watchBuffer = 256
+
+type watcher struct {
+	ids    map[uint32]struct{}
+	events chan *pb.UserEvent
+}
+
+func (w *watcher) wants(id uint32) bool {
+	if len(w.ids) == 0 {
+		return true
+	}
+	_, found := w.ids[id]
+	return found
+}
+
+func (s *UserServer) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	if invalidIDs := utils.GetInvalidIDs(req.Ids); len(invalidIDs) > 0 {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidID, invalidIDs)
+	}
+
+	w := s.subscribe(req.Ids)
+	defer s.unsubscribe(w)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-w.events:
+			if !ok {
+				return errors.ErrWatchOverflow
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *UserServer) subscribe(ids []uint32) *watcher {
+	w := &watcher{ids: make(map[uint32]struct{}), events: make(chan *pb.UserEvent, watchBuffer)}
+	for _, id := range ids {
+		w.ids[id] = struct{}{}
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+func (s *UserServer) unsubscribe(w *watcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	delete(s.watchers, w)
+}
+
+// publish notifies watchers of a change. The caller must hold mu so events
+// are delivered in the order writes were applied.
+func (s *UserServer) publish(eventType pb.UserEvent_Type, user *pb.User) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	event := &pb.UserEvent{Type: eventType, User: user}
+	for w := range s.watchers {
+		if !w.wants(user.Id) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			close(w.events)
+			delete(s.watchers, w)
+		}
+	}
+}
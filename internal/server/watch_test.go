@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchEvents(t *testing.T) {
+	userServer := NewUserServer()
+	all := userServer.subscribe(nil)
+	onlyBob := userServer.subscribe([]uint32{2})
+	defer userServer.unsubscribe(all)
+	defer userServer.unsubscribe(onlyBob)
+
+	created, err := userServer.CreateUser(context.Background(), &pb.CreateUserRequest{
+		User: &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4},
+	})
+	assert.NoError(t, err)
+	_, err = userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 2})
+	assert.NoError(t, err)
+
+	event := <-all.events
+	assert.Equal(t, pb.UserEvent_CREATED, event.Type)
+	assert.Equal(t, created.User.Id, event.User.Id)
+	event = <-all.events
+	assert.Equal(t, pb.UserEvent_DELETED, event.Type)
+	assert.Equal(t, uint32(2), event.User.Id)
+
+	event = <-onlyBob.events
+	assert.Equal(t, pb.UserEvent_DELETED, event.Type)
+	assert.Empty(t, onlyBob.events)
+}
+
+func TestWatchOverflowDisconnects(t *testing.T) {
+	userServer := NewUserServer()
+	w := userServer.subscribe(nil)
+
+	userServer.lock()
+	for i := 0; i <= watchBuffer; i++ {
+		userServer.publish(pb.UserEvent_UPDATED, userServer.users[1])
+	}
+	userServer.mu.Unlock()
+
+	for range w.events {
+	}
+	_, subscribed := userServer.watchers[w]
+	assert.False(t, subscribed)
+}
@@ -0,0 +1,120 @@
+// Package tenant resolves the tenant a call acts for, so one deployment can
+// serve several isolated user namespaces.
+package tenant
+
+import (
+	"context"
+	"regexp"
+
+	"user-service-module/internal/auth"
+	"user-service-module/internal/errors"
+	"user-service-module/pkg/userapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the request metadata entry naming the tenant.
+	Header = userapi.TenantHeader
+	// Default is the tenant of calls that name none.
+	Default = "default"
+)
+
+// validName matches tenant names: lowercase letters, digits and dashes.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type tenantKey struct{}
+
+// NewContext returns a copy of ctx acting for tenant.
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant stored in ctx, or Default.
+func FromContext(ctx context.Context) string {
+	if t, ok := ctx.Value(tenantKey{}).(string); ok {
+		return t
+	}
+	return Default
+}
+
+// Resolver picks the tenant of incoming calls.
+type Resolver struct {
+	allowed     map[string]bool
+	crossTenant func(ctx context.Context) bool
+}
+
+// NewResolver returns a Resolver accepting the given tenants, or any valid
+// tenant name when allowed is empty. Callers not bound to a tenant may only
+// act for a tenant other than Default when crossTenant reports true for the
+// call; a nil crossTenant lets every caller pick any tenant.
+func NewResolver(allowed []string, crossTenant func(ctx context.Context) bool) *Resolver {
+	r := &Resolver{allowed: make(map[string]bool, len(allowed)), crossTenant: crossTenant}
+	for _, t := range allowed {
+		r.allowed[t] = true
+	}
+	return r
+}
+
+// Resolve returns the tenant of an incoming call. A caller whose identity is
+// bound to a tenant always acts for it and may only name that tenant in the
+// metadata; other callers act for the tenant they name if allowed to, or
+// Default.
+func (r *Resolver) Resolve(ctx context.Context) (string, error) {
+	requested := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(Header); len(values) > 0 {
+		requested = values[0]
+	}
+
+	name := requested
+	if id, ok := auth.FromContext(ctx); ok && id.Tenant != "" {
+		if requested != "" && requested != id.Tenant {
+			return "", errors.Errorf("%w: %s is bound to tenant %q", errors.ErrPermissionDenied, id.Subject, id.Tenant)
+		}
+		name = id.Tenant
+	} else if requested != "" && requested != Default && r.crossTenant != nil && !r.crossTenant(ctx) {
+		return "", errors.Errorf("%w: acting for tenant %q needs a token bound to it", errors.ErrPermissionDenied, requested)
+	}
+	if name == "" {
+		name = Default
+	}
+
+	if !validName.MatchString(name) || (len(r.allowed) > 0 && !r.allowed[name]) {
+		return "", errors.Errorf("%w: unknown tenant %q", errors.ErrPermissionDenied, name)
+	}
+	return name, nil
+}
+
+// UnaryServerInterceptor stores the tenant of the call in the handler context.
+func (r *Resolver) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		name, err := r.Resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, name), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func (r *Resolver) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		name, err := r.Resolve(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: NewContext(ss.Context(), name)})
+	}
+}
+
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,65 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"user-service-module/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func naming(tenant string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(Header, tenant))
+}
+
+func bound(ctx context.Context, tenant string) context.Context {
+	return auth.NewContext(ctx, &auth.Identity{Subject: "alice", Tenant: tenant})
+}
+
+func TestResolve(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		allowed []string
+		// Whether callers not bound to a tenant may pick one, when set
+		crossTenant *bool
+		want        string
+		wantCode    codes.Code
+	}{
+		{name: "should default without a tenant", ctx: context.Background(), want: Default},
+		{name: "should use the named tenant", ctx: naming("acme"), want: "acme"},
+		{name: "should use the tenant of the identity", ctx: bound(context.Background(), "acme"), want: "acme"},
+		{name: "should accept the tenant of the identity by name", ctx: bound(naming("acme"), "acme"), want: "acme"},
+		{name: "should let callers without a tenant pick one", ctx: bound(naming("globex"), ""), want: "globex"},
+		{name: "should let callers with cross-tenant access pick one", ctx: bound(naming("globex"), ""), crossTenant: &yes, want: "globex"},
+		{name: "should reject other tenants for callers without cross-tenant access", ctx: bound(naming("globex"), ""), crossTenant: &no, wantCode: codes.PermissionDenied},
+		{name: "should default callers without cross-tenant access", ctx: bound(context.Background(), ""), crossTenant: &no, want: Default},
+		{name: "should keep tenant-bound callers in their tenant without cross-tenant access", ctx: bound(naming("acme"), "acme"), crossTenant: &no, want: "acme"},
+		{name: "should reject another tenant than the identity's", ctx: bound(naming("globex"), "acme"), wantCode: codes.PermissionDenied},
+		{name: "should reject invalid names", ctx: naming("Acme Corp"), wantCode: codes.PermissionDenied},
+		{name: "should accept allowed tenants", ctx: naming("acme"), allowed: []string{"acme", Default}, want: "acme"},
+		{name: "should reject tenants not allowed", ctx: naming("globex"), allowed: []string{"acme", Default}, wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var crossTenant func(ctx context.Context) bool
+			if tt.crossTenant != nil {
+				crossTenant = func(ctx context.Context) bool { return *tt.crossTenant }
+			}
+			got, err := NewResolver(tt.allowed, crossTenant).Resolve(tt.ctx)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, Default, FromContext(context.Background()))
+	assert.Equal(t, "acme", FromContext(NewContext(context.Background(), "acme")))
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"user-service-module/internal/server"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor()))
+	pb.RegisterUserServiceServer(s, server.NewUserServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = pb.NewUserServiceClient(conn).ListUsers(context.Background(), &pb.ListUsersRequest{Ids: []uint32{1, 2}})
+	assert.NoError(t, err)
+
+	ended := recorder.Ended()
+	assert.Len(t, ended, 4)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range ended {
+		spans[span.SpanKind().String()+" "+span.Name()] = span
+	}
+
+	client := spans["client proto.UserService/ListUsers"]
+	if !assert.NotNil(t, client) {
+		return
+	}
+	for _, name := range []string{"internal validate", "internal store.list"} {
+		child := spans[name]
+		if assert.NotNil(t, child, name) {
+			assert.Equal(t, client.SpanContext().TraceID(), child.SpanContext().TraceID())
+		}
+	}
+
+	serverSpan := spans["server proto.UserService/ListUsers"]
+	if assert.NotNil(t, serverSpan, "server span should continue the client trace") {
+		assert.True(t, serverSpan.Parent().IsRemote())
+		assert.Equal(t, client.SpanContext().TraceID(), serverSpan.SpanContext().TraceID())
+		attrs := map[string]any{}
+		for _, kv := range serverSpan.Attributes() {
+			attrs[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		assert.Equal(t, []int64{1, 2}, attrs["user.ids"])
+		assert.Equal(t, int64(2), attrs["user.result_count"])
+	}
+}
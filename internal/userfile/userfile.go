@@ -0,0 +1,355 @@
+// Package userfile reads and writes users as NDJSON or CSV files.
+package userfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// File formats.
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Columns names the CSV columns, matching the User proto field names with the
+// address fields flattened and the attributes as a JSON object. The version
+// and times are written on export and ignored by the server on import.
+var Columns = []string{
+	"id", "fname", "city", "phone", "height", "isMarried", "version",
+	"lname", "email", "dateOfBirth", "street", "region", "postalCode", "country", "createTime", "updateTime",
+	"attributes",
+}
+
+// maxLine bounds the length of one NDJSON line.
+const maxLine = 1 << 20
+
+// FormatFromPath picks the format matching the extension of path.
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl", ".json":
+		return FormatNDJSON, nil
+	case ".csv":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %q from its extension, want .ndjson, .jsonl or .csv", path)
+	}
+}
+
+func checkFormat(format string) error {
+	if format != FormatNDJSON && format != FormatCSV {
+		return fmt.Errorf("unknown format %q, want %s or %s", format, FormatNDJSON, FormatCSV)
+	}
+	return nil
+}
+
+// RowError reports a row that could not be parsed. Reading can continue with
+// the next row.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads users one row at a time.
+type Reader struct {
+	format string
+	line   int
+
+	scanner *bufio.Scanner
+
+	csv     *csv.Reader
+	columns []string
+}
+
+// NewReader returns a Reader for r. For CSV the header row is read first and
+// may list the columns in any order; missing columns are left empty.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	if format == FormatNDJSON {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLine)
+		return &Reader{format: format, scanner: scanner}, nil
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("empty CSV file, want a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range header {
+		column, found := lookupColumn(name)
+		if !found {
+			return nil, fmt.Errorf("unknown CSV column %q, want some of %s", name, strings.Join(Columns, ", "))
+		}
+		header[i] = column
+	}
+	return &Reader{format: format, csv: cr, columns: header, line: 1}, nil
+}
+
+func lookupColumn(name string) (string, bool) {
+	for _, c := range Columns {
+		if strings.EqualFold(c, strings.TrimSpace(name)) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// Read returns the next user. It returns a *RowError for a row that cannot
+// be parsed, io.EOF after the last row, and any other error when the input
+// cannot be read further.
+func (r *Reader) Read() (*pb.User, error) {
+	if r.format == FormatNDJSON {
+		return r.readNDJSON()
+	}
+	return r.readCSV()
+}
+
+// Line returns the line of the row last read.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+func (r *Reader) readNDJSON() (*pb.User, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		user := &pb.User{}
+		if err := protojson.Unmarshal([]byte(line), user); err != nil {
+			return nil, &RowError{Line: r.line, Err: err}
+		}
+		return user, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *Reader) readCSV() (*pb.User, error) {
+	record, err := r.csv.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		r.line = parseErr.StartLine
+		return nil, &RowError{Line: r.line, Err: parseErr.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.line, _ = r.csv.FieldPos(0)
+
+	if len(record) != len(r.columns) {
+		return nil, &RowError{Line: r.line, Err: fmt.Errorf("got %d fields, want %d", len(record), len(r.columns))}
+	}
+	user := &pb.User{}
+	for i, value := range record {
+		if err := setField(user, r.columns[i], strings.TrimSpace(value)); err != nil {
+			return nil, &RowError{Line: r.line, Err: err}
+		}
+	}
+	return user, nil
+}
+
+func setField(user *pb.User, column, value string) error {
+	switch column {
+	case "id":
+		if value == "" {
+			return nil
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", value)
+		}
+		user.Id = uint32(id)
+	case "fname":
+		user.Fname = value
+	case "city":
+		user.City = value
+	case "phone":
+		user.Phone = value
+	case "height":
+		if value == "" {
+			return nil
+		}
+		height, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("invalid height %q", value)
+		}
+		user.Height = float32(height)
+	case "isMarried":
+		if value == "" {
+			return nil
+		}
+		status, found := pb.MaritalStatus_value[strings.ToUpper(value)]
+		if !found {
+			return fmt.Errorf("invalid isMarried %q", value)
+		}
+		user.IsMarried = pb.MaritalStatus(status)
+	case "version":
+		if value == "" {
+			return nil
+		}
+		version, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", value)
+		}
+		user.Version = uint32(version)
+	case "lname":
+		user.Lname = value
+	case "email":
+		user.Email = value
+	case "dateOfBirth":
+		user.DateOfBirth = value
+	case "street", "region", "postalCode", "country":
+		if value == "" {
+			return nil
+		}
+		if user.Address == nil {
+			user.Address = &pb.Address{}
+		}
+		switch column {
+		case "street":
+			user.Address.Street = value
+		case "region":
+			user.Address.Region = value
+		case "postalCode":
+			user.Address.PostalCode = value
+		default:
+			user.Address.Country = value
+		}
+	case "createTime", "updateTime":
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", column, value)
+		}
+		if column == "createTime" {
+			user.CreateTime = timestamppb.New(t)
+		} else {
+			user.UpdateTime = timestamppb.New(t)
+		}
+	case "attributes":
+		if value == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), &user.Attributes); err != nil {
+			return fmt.Errorf("invalid attributes %q, want a JSON object of strings", value)
+		}
+	}
+	return nil
+}
+
+// formatAttributes formats attributes for CSV as a JSON object with sorted
+// keys, or returns "" when there are none.
+func formatAttributes(attributes map[string]string) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(attributes)
+	return string(b)
+}
+
+// formatTime formats t for CSV, or returns "" when it is unset.
+func formatTime(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.AsTime().Format(time.RFC3339Nano)
+}
+
+// Writer writes users in one format. Call Flush when done.
+type Writer struct {
+	format string
+	bw     *bufio.Writer
+	csv    *csv.Writer
+}
+
+var jsonOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// NewWriter returns a Writer for w. For CSV the header row is written first.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	if format == FormatNDJSON {
+		return &Writer{format: format, bw: bufio.NewWriter(w)}, nil
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(Columns)
+	return &Writer{format: format, csv: cw}, nil
+}
+
+// Write writes one user.
+func (w *Writer) Write(user *pb.User) error {
+	if w.format == FormatNDJSON {
+		b, err := jsonOptions.Marshal(user)
+		if err != nil {
+			return err
+		}
+		w.bw.Write(b)
+		return w.bw.WriteByte('\n')
+	}
+	return w.csv.Write([]string{
+		strconv.FormatUint(uint64(user.Id), 10),
+		user.Fname,
+		user.City,
+		user.Phone,
+		strconv.FormatFloat(float64(user.Height), 'f', -1, 32),
+		user.IsMarried.String(),
+		strconv.FormatUint(uint64(user.Version), 10),
+		user.Lname,
+		user.Email,
+		user.DateOfBirth,
+		user.GetAddress().GetStreet(),
+		user.GetAddress().GetRegion(),
+		user.GetAddress().GetPostalCode(),
+		user.GetAddress().GetCountry(),
+		formatTime(user.CreateTime),
+		formatTime(user.UpdateTime),
+		formatAttributes(user.Attributes),
+	})
+}
+
+// Flush writes any buffered data.
+func (w *Writer) Flush() error {
+	if w.format == FormatNDJSON {
+		return w.bw.Flush()
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,136 @@
+package userfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var users = []*pb.User{
+	{Id: 1, Fname: "Steve", City: "LA", Phone: "9827329211", Height: 5.8, IsMarried: pb.MaritalStatus_MARRIED, Version: 3},
+	{Id: 2, Fname: "Bob", City: "New York", Phone: "9876543210", Height: 6.1, IsMarried: pb.MaritalStatus_SINGLE, Version: 1},
+	{
+		Id: 3, Fname: "Carol", Lname: "Smith", Email: "carol@example.com", DateOfBirth: "1990-02-28",
+		City: "SF", Phone: "9123456789", Height: 5.4, Version: 2,
+		Address:    &pb.Address{Street: "1 Market St, Suite 2", Region: "California", PostalCode: "94105", Country: "US"},
+		CreateTime: timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2024, 5, 2, 8, 30, 0, 500, time.UTC)),
+		Attributes: map[string]string{"team": "core, \"platform\"", "level": "3"},
+	},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatNDJSON, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			assert.NoError(t, err)
+			for _, u := range users {
+				assert.NoError(t, w.Write(u))
+			}
+			assert.NoError(t, w.Flush())
+
+			r, err := NewReader(&buf, format)
+			assert.NoError(t, err)
+			for _, want := range users {
+				got, err := r.Read()
+				assert.NoError(t, err)
+				assert.Equal(t, want.String(), got.String())
+			}
+			_, err = r.Read()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+// readAll collects users and row errors until the end of the input.
+func readAll(t *testing.T, r *Reader) ([]string, []string) {
+	t.Helper()
+	var got, rowErrs []string
+	for {
+		u, err := r.Read()
+		var rowErr *RowError
+		switch {
+		case errors.Is(err, io.EOF):
+			return got, rowErrs
+		case errors.As(err, &rowErr):
+			rowErrs = append(rowErrs, rowErr.Error())
+		case err != nil:
+			t.Fatal(err)
+		default:
+			got = append(got, u.Fname)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "City, fname ,isMarried\n" +
+		"LA,Steve,married\n" +
+		"NY,Bob,maybe\n" +
+		"SF\n" +
+		"\"Salt Lake City\",Carol,\n"
+
+	r, err := NewReader(strings.NewReader(input), FormatCSV)
+	assert.NoError(t, err)
+	got, rowErrs := readAll(t, r)
+
+	assert.Equal(t, []string{"Steve", "Carol"}, got)
+	assert.Equal(t, []string{`line 3: invalid isMarried "maybe"`, "line 4: got 1 fields, want 3"}, rowErrs)
+}
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"id": 1, "fname": "Steve"}` + "\n" +
+		"\n" +
+		`{"fname": "Bob", "age": 30}` + "\n" +
+		`{"fname": "Carol", "isMarried": "SINGLE"}` + "\n"
+
+	r, err := NewReader(strings.NewReader(input), FormatNDJSON)
+	assert.NoError(t, err)
+	got, rowErrs := readAll(t, r)
+
+	assert.Equal(t, []string{"Steve", "Carol"}, got)
+	assert.Len(t, rowErrs, 1)
+	assert.True(t, strings.HasPrefix(rowErrs[0], "line 3: "), rowErrs[0])
+	assert.Equal(t, 4, r.Line())
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	_, err := NewReader(strings.NewReader("id,age\n"), FormatCSV)
+	assert.EqualError(t, err, `unknown CSV column "age", want some of id, fname, city, phone, height, isMarried, version, lname, email, dateOfBirth, street, region, postalCode, country, createTime, updateTime, attributes`)
+
+	_, err = NewReader(strings.NewReader(""), FormatCSV)
+	assert.EqualError(t, err, "empty CSV file, want a header row")
+
+	_, err = NewReader(strings.NewReader(""), "xml")
+	assert.EqualError(t, err, `unknown format "xml", want ndjson or csv`)
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "users.ndjson", want: FormatNDJSON},
+		{path: "dir/users.JSONL", want: FormatNDJSON},
+		{path: "users.csv", want: FormatCSV},
+		{path: "users.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			format, err := FormatFromPath(tt.path)
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+)
+
+// Attribute keys are lower-case letters, digits and underscores, starting
+// with a letter
+func isAttributeKeyValid(key string) bool {
+	keyRegex := `^[a-z][a-z0-9_]{0,63}$`
+	return regexp.MustCompile(keyRegex).MatchString(key)
+}
+
+// ValidateAttributeDefinition checks the key and type of an attribute
+// definition, and that it only sets the rules of its type
+func ValidateAttributeDefinition(def *pb.AttributeDefinition) error {
+	if def == nil {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidFields, "definition must be provided")
+	}
+
+	var invalidFields []string
+	if !isAttributeKeyValid(def.Key) {
+		invalidFields = append(invalidFields, "key")
+	}
+	if _, found := pb.AttributeDefinition_Type_name[int32(def.Type)]; !found || def.Type == pb.AttributeDefinition_TYPE_UNSPECIFIED {
+		invalidFields = append(invalidFields, "type")
+	}
+	if def.MaxLength != 0 && def.Type != pb.AttributeDefinition_STRING {
+		invalidFields = append(invalidFields, "maxLength")
+	}
+	if def.Pattern != "" {
+		if _, err := regexp.Compile(def.Pattern); err != nil || def.Type != pb.AttributeDefinition_STRING {
+			invalidFields = append(invalidFields, "pattern")
+		}
+	}
+	if def.Min != nil && def.Type != pb.AttributeDefinition_INT {
+		invalidFields = append(invalidFields, "min")
+	}
+	if def.Max != nil && (def.Type != pb.AttributeDefinition_INT || def.Min != nil && *def.Max < *def.Min) {
+		invalidFields = append(invalidFields, "max")
+	}
+	if !areEnumValuesValid(def) {
+		invalidFields = append(invalidFields, "values")
+	}
+
+	if len(invalidFields) > 0 {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidFields, strings.Join(invalidFields, ", "))
+	}
+	return nil
+}
+
+// Enum attributes need distinct, non-empty values; other types take none
+func areEnumValuesValid(def *pb.AttributeDefinition) bool {
+	if def.Type != pb.AttributeDefinition_ENUM {
+		return len(def.Values) == 0
+	}
+	seen := make(map[string]struct{}, len(def.Values))
+	for _, v := range def.Values {
+		if _, dup := seen[v]; dup || v == "" || !utf8.ValidString(v) {
+			return false
+		}
+		seen[v] = struct{}{}
+	}
+	return len(def.Values) > 0
+}
+
+// IsAttributeValueValid reports whether value is the canonical text form of
+// a value of the attribute type that satisfies its rules. Values are never
+// empty; an unset attribute is left out of the map
+func IsAttributeValueValid(def *pb.AttributeDefinition, value string) bool {
+	switch def.Type {
+	case pb.AttributeDefinition_STRING:
+		if value == "" || !utf8.ValidString(value) {
+			return false
+		}
+		if def.MaxLength > 0 && utf8.RuneCountInString(value) > int(def.MaxLength) {
+			return false
+		}
+		return def.Pattern == "" || regexp.MustCompile(`^(?:`+def.Pattern+`)$`).MatchString(value)
+	case pb.AttributeDefinition_INT:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || strconv.FormatInt(n, 10) != value {
+			return false
+		}
+		return (def.Min == nil || n >= *def.Min) && (def.Max == nil || n <= *def.Max)
+	case pb.AttributeDefinition_BOOL:
+		return value == "true" || value == "false"
+	case pb.AttributeDefinition_ENUM:
+		return slices.Contains(def.Values, value)
+	default:
+		return false
+	}
+}
+
+// ValidateAttributes checks attribute values against the definitions
+// returned by lookup, which returns nil for keys that may not be used. Invalid
+// attributes are reported as "attributes.<key>"
+func ValidateAttributes(attributes map[string]string, lookup func(key string) *pb.AttributeDefinition) error {
+	var invalidFields []string
+	for key, value := range attributes {
+		if def := lookup(key); def == nil || !IsAttributeValueValid(def, value) {
+			invalidFields = append(invalidFields, "attributes."+key)
+		}
+	}
+
+	if len(invalidFields) > 0 {
+		sort.Strings(invalidFields)
+		return fmt.Errorf("%w: %v", errors.ErrInvalidFields, strings.Join(invalidFields, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"user-service-module/internal/errors"
+	pb "user-service-module/proto/user/userpb"
+)
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func TestValidateAttributeDefinition(t *testing.T) {
+	tests := []struct {
+		name        string
+		def         *pb.AttributeDefinition
+		errContains string
+	}{
+		{
+			name: "should validate a string with rules",
+			def:  &pb.AttributeDefinition{Key: "employee_id", Type: pb.AttributeDefinition_STRING, MaxLength: 8, Pattern: `E\d+`},
+		},
+		{
+			name: "should validate an int with bounds",
+			def:  &pb.AttributeDefinition{Key: "level", Type: pb.AttributeDefinition_INT, Min: int64Ptr(1), Max: int64Ptr(9), Indexed: true},
+		},
+		{
+			name: "should validate an enum",
+			def:  &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_ENUM, Values: []string{"core", "growth"}},
+		},
+		{
+			name:        "should not validate a missing definition",
+			errContains: "definition must be provided",
+		},
+		{
+			name:        "should not validate an invalid key or type",
+			def:         &pb.AttributeDefinition{Key: "Team Name"},
+			errContains: "key, type",
+		},
+		{
+			name:        "should not validate rules of another type",
+			def:         &pb.AttributeDefinition{Key: "vip", Type: pb.AttributeDefinition_BOOL, MaxLength: 3, Pattern: "x", Min: int64Ptr(1), Values: []string{"yes"}},
+			errContains: "maxLength, pattern, min, values",
+		},
+		{
+			name:        "should not validate inverted bounds",
+			def:         &pb.AttributeDefinition{Key: "level", Type: pb.AttributeDefinition_INT, Min: int64Ptr(9), Max: int64Ptr(1)},
+			errContains: "max",
+		},
+		{
+			name:        "should not validate an invalid pattern",
+			def:         &pb.AttributeDefinition{Key: "code", Type: pb.AttributeDefinition_STRING, Pattern: "("},
+			errContains: "pattern",
+		},
+		{
+			name:        "should not validate an enum with duplicate values",
+			def:         &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_ENUM, Values: []string{"core", "core"}},
+			errContains: "values",
+		},
+		{
+			name:        "should not validate an enum without values",
+			def:         &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_ENUM},
+			errContains: "values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAttributeDefinition(tt.def)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("ValidateAttributeDefinition() = %v; want nil", err)
+				}
+				return
+			}
+			expectedErr := fmt.Sprintf("%v: %v", errors.ErrInvalidFields, tt.errContains)
+			if err == nil || err.Error() != expectedErr {
+				t.Errorf("ValidateAttributeDefinition() = %v; want %v", err, expectedErr)
+			}
+		})
+	}
+}
+
+func TestIsAttributeValueValid(t *testing.T) {
+	employeeID := &pb.AttributeDefinition{Type: pb.AttributeDefinition_STRING, MaxLength: 5, Pattern: `E\d+`}
+	level := &pb.AttributeDefinition{Type: pb.AttributeDefinition_INT, Min: int64Ptr(1), Max: int64Ptr(9)}
+	vip := &pb.AttributeDefinition{Type: pb.AttributeDefinition_BOOL}
+	team := &pb.AttributeDefinition{Type: pb.AttributeDefinition_ENUM, Values: []string{"core", "growth"}}
+
+	tests := []struct {
+		def    *pb.AttributeDefinition
+		value  string
+		result bool
+	}{
+		{employeeID, "E1234", true},
+		{employeeID, "E12345", false},
+		{employeeID, "xE12", false},
+		{employeeID, "", false},
+		{&pb.AttributeDefinition{Type: pb.AttributeDefinition_STRING}, "anything at all", true},
+		{level, "9", true},
+		{level, "10", false},
+		{level, "0", false},
+		{level, "07", false},
+		{level, "+7", false},
+		{level, "seven", false},
+		{vip, "true", true},
+		{vip, "false", true},
+		{vip, "TRUE", false},
+		{vip, "1", false},
+		{team, "growth", true},
+		{team, "Growth", false},
+	}
+
+	for _, test := range tests {
+		if got := IsAttributeValueValid(test.def, test.value); got != test.result {
+			t.Errorf("IsAttributeValueValid(%v, %q) = %v; want %v", test.def.Type, test.value, got, test.result)
+		}
+	}
+}
+
+func TestValidateAttributes(t *testing.T) {
+	defs := map[string]*pb.AttributeDefinition{
+		"level": {Key: "level", Type: pb.AttributeDefinition_INT},
+		"vip":   {Key: "vip", Type: pb.AttributeDefinition_BOOL},
+	}
+	lookup := func(key string) *pb.AttributeDefinition { return defs[key] }
+
+	if err := ValidateAttributes(map[string]string{"level": "3", "vip": "true"}, lookup); err != nil {
+		t.Errorf("ValidateAttributes() = %v; want nil", err)
+	}
+	err := ValidateAttributes(map[string]string{"level": "three", "vip": "true", "team": "core"}, lookup)
+	expectedErr := fmt.Sprintf("%v: %v", errors.ErrInvalidFields, "attributes.level, attributes.team")
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("ValidateAttributes() = %v; want %v", err, expectedErr)
+	}
+}
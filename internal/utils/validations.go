@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 	"user-service-module/internal/errors"
 	pb "user-service-module/proto/user/userpb"
 )
@@ -32,27 +33,39 @@ func isCityValid(city string) bool {
 	return regexp.MustCompile(cityRegex).MatchString(city)
 }
 
-func ValidateSearchRequest(city, phone string, isMarried pb.MaritalStatus) (bool, error) {
-	if city == "" && phone == "" {
-		if isMarried == pb.MaritalStatus_UNKNOWN{
+func ValidateSearchRequest(req *pb.SearchUsersRequest) (bool, error) {
+	if req.City == "" && req.Phone == "" && req.Lname == "" && req.Email == "" && req.DateOfBirth == "" && req.Country == "" && len(req.Attributes) == 0 {
+		if req.IsMarried == pb.MaritalStatus_UNKNOWN && !req.UnknownMaritalStatus {
 			return false, fmt.Errorf(
 				"%w: %v", 
 				errors.ErrInvalidFields, 
-				"either city, phone or marital status must be provided",
+				"either city, phone, marital status, unknownMaritalStatus, lname, email, dateOfBirth, country or attributes must be provided",
 			)
-		} else {
-			return true, nil
 		}
-		
 	}
 
 	var invalidFields []string
-	if city != "" && !isCityValid(city) {
+	if !isMaritalStatusValid(req.IsMarried) {
+		invalidFields = append(invalidFields, "isMarried")
+	}
+	if req.City != "" && !isCityValid(req.City) {
 		invalidFields = append(invalidFields, "city")
 	}
-	if phone != "" && !isValidPhone(phone) {
+	if req.Phone != "" && !isValidPhone(req.Phone) {
 		invalidFields = append(invalidFields, "phone")
 	}
+	if req.Lname != "" && !isNameValid(req.Lname) {
+		invalidFields = append(invalidFields, "lname")
+	}
+	if req.Email != "" && !isEmailValid(req.Email) {
+		invalidFields = append(invalidFields, "email")
+	}
+	if req.DateOfBirth != "" && !isDateOfBirthValid(req.DateOfBirth) {
+		invalidFields = append(invalidFields, "dateOfBirth")
+	}
+	if req.Country != "" && !isCountryValid(req.Country) {
+		invalidFields = append(invalidFields, "country")
+	}
 
 	if len(invalidFields) > 0 {
 		// Join the invalid fields into a single string
@@ -61,3 +74,112 @@ func ValidateSearchRequest(city, phone string, isMarried pb.MaritalStatus) (bool
 	}
 	return true, nil
 }
+
+func isNameValid(name string) bool {
+	nameRegex := `^[a-zA-Z]+(?:[\s'-][a-zA-Z]+)*$`
+	return regexp.MustCompile(nameRegex).MatchString(name)
+}
+
+// Heights are stored in feet
+func isHeightValid(height float32) bool {
+	return height >= 1 && height <= 9
+}
+
+func isMaritalStatusValid(status pb.MaritalStatus) bool {
+	_, found := pb.MaritalStatus_name[int32(status)]
+	return found
+}
+
+func isEmailValid(email string) bool {
+	emailRegex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}$`
+	return len(email) <= 254 && regexp.MustCompile(emailRegex).MatchString(email)
+}
+
+// DateLayout is the format of dates of birth
+const DateLayout = "2006-01-02"
+
+// Dates of birth must be real dates, not in the future, from 1900 on
+func isDateOfBirthValid(date string) bool {
+	born, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return false
+	}
+	return born.Year() >= 1900 && !born.After(time.Now())
+}
+
+func isStreetValid(street string) bool {
+	streetRegex := `^[\p{L}\p{N}][\p{L}\p{N}\s.,'#/-]{0,99}$`
+	return regexp.MustCompile(streetRegex).MatchString(street)
+}
+
+func isPostalCodeValid(code string) bool {
+	postalCodeRegex := `^[a-zA-Z0-9][a-zA-Z0-9 -]{1,9}$`
+	return regexp.MustCompile(postalCodeRegex).MatchString(code)
+}
+
+// Countries are ISO 3166-1 alpha-2 codes
+func isCountryValid(country string) bool {
+	countryRegex := `^[A-Z]{2}$`
+	return regexp.MustCompile(countryRegex).MatchString(country)
+}
+
+// validateAddress returns the invalid fields of an address; every field is
+// optional
+func validateAddress(address *pb.Address) []string {
+	var invalidFields []string
+	if address.Street != "" && !isStreetValid(address.Street) {
+		invalidFields = append(invalidFields, "address.street")
+	}
+	if address.Region != "" && !isCityValid(address.Region) {
+		invalidFields = append(invalidFields, "address.region")
+	}
+	if address.PostalCode != "" && !isPostalCodeValid(address.PostalCode) {
+		invalidFields = append(invalidFields, "address.postalCode")
+	}
+	if address.Country != "" && !isCountryValid(address.Country) {
+		invalidFields = append(invalidFields, "address.country")
+	}
+	return invalidFields
+}
+
+// ValidateUser checks every user-supplied field of a user being written. The
+// fields added after the first release are optional
+func ValidateUser(user *pb.User) error {
+	if user == nil {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidFields, "user must be provided")
+	}
+
+	var invalidFields []string
+	if !isNameValid(user.Fname) {
+		invalidFields = append(invalidFields, "fname")
+	}
+	if !isCityValid(user.City) {
+		invalidFields = append(invalidFields, "city")
+	}
+	if !isValidPhone(user.Phone) {
+		invalidFields = append(invalidFields, "phone")
+	}
+	if !isHeightValid(user.Height) {
+		invalidFields = append(invalidFields, "height")
+	}
+	if !isMaritalStatusValid(user.IsMarried) {
+		invalidFields = append(invalidFields, "isMarried")
+	}
+	if user.Lname != "" && !isNameValid(user.Lname) {
+		invalidFields = append(invalidFields, "lname")
+	}
+	if user.Email != "" && !isEmailValid(user.Email) {
+		invalidFields = append(invalidFields, "email")
+	}
+	if user.DateOfBirth != "" && !isDateOfBirthValid(user.DateOfBirth) {
+		invalidFields = append(invalidFields, "dateOfBirth")
+	}
+	if user.Address != nil {
+		invalidFields = append(invalidFields, validateAddress(user.Address)...)
+	}
+
+	if len(invalidFields) > 0 {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidFields, strings.Join(invalidFields, ", "))
+	}
+	return nil
+}
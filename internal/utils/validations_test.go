@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 	"user-service-module/internal/errors"
 	pb "user-service-module/proto/user/userpb"
 )
@@ -215,13 +217,13 @@ func TestValidateSearchRequest(t *testing.T) {
 			phone:      "",
 			isMarried:  pb.MaritalStatus_UNKNOWN,
 			isValid:    false,
-			errContains: "either city, phone or marital status must be provided",
+			errContains: "either city, phone, marital status, unknownMaritalStatus, lname, email, dateOfBirth, country or attributes must be provided",
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			valid, err := ValidateSearchRequest(test.city, test.phone, test.isMarried)
+			valid, err := ValidateSearchRequest(&pb.SearchUsersRequest{City: test.city, Phone: test.phone, IsMarried: test.isMarried})
 			if valid != test.isValid {
 				t.Errorf("ValidateSearchRequest(%q, %q) valid = %v; want %v", test.city, test.phone, valid, test.isValid)
 			}
@@ -236,3 +238,173 @@ func TestValidateSearchRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSearchRequestNewFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *pb.SearchUsersRequest
+		errContains string
+	}{
+		{name: "should validate a last name alone", req: &pb.SearchUsersRequest{Lname: "O'Brien"}},
+		{name: "should validate an email alone", req: &pb.SearchUsersRequest{Email: "carol@example.com"}},
+		{name: "should validate a date of birth alone", req: &pb.SearchUsersRequest{DateOfBirth: "1990-02-28"}},
+		{name: "should validate a country alone", req: &pb.SearchUsersRequest{Country: "US"}},
+		{name: "should validate a new marital status alone", req: &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus_DOMESTIC_PARTNERSHIP}},
+		{name: "should validate an unknown marital status alone", req: &pb.SearchUsersRequest{UnknownMaritalStatus: true}},
+		{name: "should validate attributes alone", req: &pb.SearchUsersRequest{Attributes: map[string]string{"team": "core"}}},
+		{
+			name:        "should not validate an undefined marital status",
+			req:         &pb.SearchUsersRequest{IsMarried: pb.MaritalStatus(99)},
+			errContains: "isMarried",
+		},
+		{
+			name:        "should list every invalid field",
+			req:         &pb.SearchUsersRequest{Lname: "1", Email: "carol", DateOfBirth: "1990-02-30", Country: "usa"},
+			errContains: "lname, email, dateOfBirth, country",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := ValidateSearchRequest(tt.req)
+			if tt.errContains == "" {
+				if !valid || err != nil {
+					t.Errorf("ValidateSearchRequest() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			expectedErr := fmt.Sprintf("%v: %v", errors.ErrInvalidFields, tt.errContains)
+			if valid || err == nil || err.Error() != expectedErr {
+				t.Errorf("ValidateSearchRequest() = %v, %v; want false, %v", valid, err, expectedErr)
+			}
+		})
+	}
+}
+
+func TestIsEmailValid(t *testing.T) {
+	tests := []struct {
+		email  string
+		result bool
+	}{
+		{"carol@example.com", true},
+		{"carol.smith+news@mail.example.co.uk", true},
+		{"carol@example", false},
+		{"carol@@example.com", false},
+		{"carol smith@example.com", false},
+		{"@example.com", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isEmailValid(test.email); got != test.result {
+			t.Errorf("isEmailValid(%s) = %v; want %v", test.email, got, test.result)
+		}
+	}
+}
+
+func TestIsDateOfBirthValid(t *testing.T) {
+	tests := []struct {
+		date   string
+		result bool
+	}{
+		{"1990-02-28", true},
+		{"2000-02-29", true},
+		{"1990-02-30", false},
+		{"1899-12-31", false},
+		{time.Now().AddDate(0, 0, 1).Format(DateLayout), false},
+		{"28/02/1990", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isDateOfBirthValid(test.date); got != test.result {
+			t.Errorf("isDateOfBirthValid(%s) = %v; want %v", test.date, got, test.result)
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address *pb.Address
+		invalid []string
+	}{
+		{name: "should validate a complete address", address: &pb.Address{Street: "1 Main St.", Region: "California", PostalCode: "94105", Country: "US"}},
+		{name: "should validate an empty address", address: &pb.Address{}},
+		{name: "should validate a postal code with letters", address: &pb.Address{PostalCode: "SW1A 1AA", Country: "GB"}},
+		{
+			name:    "should list every invalid field",
+			address: &pb.Address{Street: "!", Region: "42", PostalCode: "9", Country: "usa"},
+			invalid: []string{"address.street", "address.region", "address.postalCode", "address.country"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateAddress(tt.address); strings.Join(got, ",") != strings.Join(tt.invalid, ",") {
+				t.Errorf("validateAddress() = %v; want %v", got, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	valid := func() *pb.User {
+		return &pb.User{Fname: "Steve", City: "LA", Phone: "9827329211", Height: 5.8, IsMarried: pb.MaritalStatus_MARRIED}
+	}
+
+	tests := []struct {
+		name        string
+		user        *pb.User
+		errContains string
+	}{
+		{name: "should validate a complete user", user: valid()},
+		{name: "should validate unknown marital status", user: func() *pb.User { u := valid(); u.IsMarried = pb.MaritalStatus_UNKNOWN; return u }()},
+		{name: "should not validate nil user", user: nil, errContains: "user must be provided"},
+		{name: "should not validate empty name", user: func() *pb.User { u := valid(); u.Fname = ""; return u }(), errContains: "fname"},
+		{name: "should not validate invalid city", user: func() *pb.User { u := valid(); u.City = "123City"; return u }(), errContains: "city"},
+		{name: "should not validate invalid phone", user: func() *pb.User { u := valid(); u.Phone = "123"; return u }(), errContains: "phone"},
+		{name: "should not validate implausible height", user: func() *pb.User { u := valid(); u.Height = 12; return u }(), errContains: "height"},
+		{name: "should not validate undefined marital status", user: func() *pb.User { u := valid(); u.IsMarried = 42; return u }(), errContains: "isMarried"},
+		{
+			name:        "should list every invalid field",
+			user:        &pb.User{Fname: "Steve", City: "1", Phone: "1", Height: 5, IsMarried: -1},
+			errContains: "city, phone, isMarried",
+		},
+		{
+			name: "should validate the optional profile fields",
+			user: func() *pb.User {
+				u := valid()
+				u.Lname, u.Email, u.DateOfBirth = "Jobs", "steve@example.com", "1955-02-24"
+				u.Address = &pb.Address{Street: "1 Infinite Loop", Region: "California", PostalCode: "95014", Country: "US"}
+				return u
+			}(),
+		},
+		{
+			name: "should not validate invalid profile fields",
+			user: func() *pb.User {
+				u := valid()
+				u.Lname, u.Email, u.DateOfBirth = "J0bs", "steve", "1955-13-01"
+				u.Address = &pb.Address{Country: "United States"}
+				return u
+			}(),
+			errContains: "lname, email, dateOfBirth, address.country",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUser(tt.user)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("ValidateUser() error = %v; want nil", err)
+				}
+				return
+			}
+			expectedErr := fmt.Sprintf("%v: %v", errors.ErrInvalidFields, tt.errContains)
+			if err == nil || err.Error() != expectedErr {
+				t.Errorf("ValidateUser() error = %v; want %v", err, expectedErr)
+			}
+		})
+	}
+}
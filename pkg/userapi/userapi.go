@@ -0,0 +1,46 @@
+// Package userapi holds the parts of the UserService protocol that are not in
+// the proto definitions: the request and response metadata keys, and the
+// reasons set in the google.rpc.ErrorInfo detail of failed calls. Both the
+// server and clients use it, so it must not depend on server packages.
+package userapi
+
+// Metadata keys.
+const (
+	// RequestIDHeader carries the request ID in both directions.
+	RequestIDHeader = "x-request-id"
+	// TenantHeader names the tenant a call acts for.
+	TenantHeader = "x-tenant-id"
+	// IdempotencyKeyHeader carries the idempotency key of a write.
+	IdempotencyKeyHeader = "idempotency-key"
+	// ReplayedHeader is set to "true" in the response header when the
+	// response is a replay of an earlier call with the same idempotency key.
+	ReplayedHeader = "idempotent-replayed"
+	// RetryAfterTrailer carries the number of seconds a throttled client
+	// should wait before retrying.
+	RetryAfterTrailer = "retry-after"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details set by the server.
+const ErrorDomain = "user-service"
+
+// Reasons of the ErrorInfo details set by the server. Calls failing for a
+// reason not listed here may carry no ErrorInfo.
+const (
+	ReasonInvalidID            = "INVALID_ID"
+	ReasonInvalidFields        = "INVALID_FIELDS"
+	ReasonUserNotFound         = "USER_NOT_FOUND"
+	ReasonUserExists           = "USER_EXISTS"
+	ReasonVersionMismatch      = "VERSION_MISMATCH"
+	ReasonUnauthenticated      = "UNAUTHENTICATED"
+	ReasonPermissionDenied     = "PERMISSION_DENIED"
+	ReasonIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	ReasonQuotaExceeded        = "QUOTA_EXCEEDED"
+	ReasonAttributeNotFound    = "ATTRIBUTE_NOT_FOUND"
+	ReasonAttributeInUse       = "ATTRIBUTE_IN_USE"
+	ReasonUniqueViolation      = "UNIQUE_VIOLATION"
+	ReasonWatchOverflow        = "WATCH_OVERFLOW"
+	// ReasonRateLimited is set when the caller exceeded its rate limit.
+	ReasonRateLimited = "RATE_LIMITED"
+	// ReasonOverloaded is set when the server has too many calls in flight.
+	ReasonOverloaded = "OVERLOADED"
+)
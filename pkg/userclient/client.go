@@ -0,0 +1,542 @@
+// Package userclient is the Go client SDK for UserService. It wraps the
+// generated gRPC client with typed methods, default deadlines, retries with
+// jittered backoff and typed errors.
+package userclient
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"user-service-module/pkg/userapi"
+	pb "user-service-module/proto/user/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Client calls UserService.
+type Client struct {
+	rpc  pb.UserServiceClient
+	conn *grpc.ClientConn
+	opts options
+}
+
+// SearchQuery selects users matching any of the set criteria.
+type SearchQuery struct {
+	City  string
+	Phone string
+	// MaritalStatus is ignored when UNKNOWN; set UnknownMaritalStatus to
+	// match users whose status is UNKNOWN.
+	MaritalStatus        pb.MaritalStatus
+	UnknownMaritalStatus bool
+	Lname                string
+	Email                string
+	// DateOfBirth is formatted as YYYY-MM-DD.
+	DateOfBirth string
+	// Country is the ISO 3166-1 alpha-2 code of the address country.
+	Country string
+	// Attributes holds values of indexed attributes to match, by key.
+	Attributes map[string]string
+}
+
+// New connects to the server at addr.
+func New(addr string, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(o.creds)}, o.dialOptions...)
+	conn, err := grpc.NewClient(addr, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpc: pb.NewUserServiceClient(conn), conn: conn, opts: o}, nil
+}
+
+// NewFromConn returns a Client using an existing connection, which the
+// caller remains responsible for closing. Dial related options are ignored.
+func NewFromConn(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Client{rpc: pb.NewUserServiceClient(conn), opts: o}
+}
+
+// Close closes the connection opened by New.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// GetUser returns the user with the given ID.
+func (c *Client) GetUser(ctx context.Context, id uint32) (*pb.User, error) {
+	var resp *pb.GetUserResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.GetUser(ctx, &pb.GetUserRequest{Id: id})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// ListUsers returns the users with the given IDs. It fails when any of them
+// does not exist.
+func (c *Client) ListUsers(ctx context.Context, ids []uint32) ([]*pb.User, error) {
+	var resp *pb.ListUsersResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.ListUsers(ctx, &pb.ListUsersRequest{Ids: ids})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
+
+// SearchUsers returns the users matching q.
+func (c *Client) SearchUsers(ctx context.Context, q SearchQuery) ([]*pb.User, error) {
+	var resp *pb.SearchUsersResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.SearchUsers(ctx, &pb.SearchUsersRequest{
+			City:                 q.City,
+			Phone:                q.Phone,
+			IsMarried:            q.MaritalStatus,
+			UnknownMaritalStatus: q.UnknownMaritalStatus,
+			Lname:                q.Lname,
+			Email:                q.Email,
+			DateOfBirth:          q.DateOfBirth,
+			Country:              q.Country,
+			Attributes:           q.Attributes,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
+
+// CreateUser creates user and returns it with its server-assigned ID.
+func (c *Client) CreateUser(ctx context.Context, user *pb.User) (*pb.User, error) {
+	var resp *pb.CreateUserResponse
+	err := c.write(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.CreateUser(ctx, &pb.CreateUserRequest{User: user})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// UpdateUser updates the named fields of the user with user.Id, or every
+// field when none are named, and returns the updated user. When user.Version
+// is set, as it is on a user read from the server, the update fails with
+// ErrVersionMismatch if the user was changed since.
+func (c *Client) UpdateUser(ctx context.Context, user *pb.User, fields ...string) (*pb.User, error) {
+	req := &pb.UpdateUserRequest{User: user, ExpectedVersion: user.GetVersion()}
+	if len(fields) > 0 {
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: fields}
+	}
+
+	var resp *pb.UpdateUserResponse
+	err := c.write(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.UpdateUser(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// DeleteUser deletes the user with the given ID.
+func (c *Client) DeleteUser(ctx context.Context, id uint32) error {
+	return c.DeleteUserAtVersion(ctx, id, 0)
+}
+
+// DeleteUserAtVersion deletes the user with the given ID if it is still at
+// version, and fails with ErrVersionMismatch otherwise. A version of 0
+// deletes the user whatever its version.
+func (c *Client) DeleteUserAtVersion(ctx context.Context, id uint32, version uint32) error {
+	return c.write(ctx, func(ctx context.Context) error {
+		_, err := c.rpc.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id, ExpectedVersion: version})
+		return err
+	})
+}
+
+// GetUserHistory returns one page of the revisions of the user with the
+// given ID, oldest first, and the token of the next page, which is empty on
+// the last page. Pass an empty token for the first page and a pageSize of 0
+// for the server default. History is kept after a user is deleted.
+func (c *Client) GetUserHistory(ctx context.Context, id uint32, pageSize uint32, pageToken string) ([]*pb.UserRevision, string, error) {
+	var resp *pb.GetUserHistoryResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.GetUserHistory(ctx, &pb.GetUserHistoryRequest{Id: id, PageSize: pageSize, PageToken: pageToken})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Revisions, resp.NextPageToken, nil
+}
+
+// GetUserAsOf returns the revision of the user with the given ID in effect at
+// t. It fails with ErrUserNotFound when the user did not exist at t.
+func (c *Client) GetUserAsOf(ctx context.Context, id uint32, t time.Time) (*pb.UserRevision, error) {
+	var resp *pb.GetUserHistoryResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.GetUserHistory(ctx, &pb.GetUserHistoryRequest{Id: id, AsOf: timestamppb.New(t)})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Revisions) == 0 {
+		return nil, reasonError(codes.NotFound, userapi.ReasonUserNotFound, fmt.Sprintf("%v: %d", ErrUserNotFound, id))
+	}
+	return resp.Revisions[0], nil
+}
+
+// ExportUserData returns the signed zip archive of everything the server
+// holds about the user with the given ID, for a subject-access request.
+func (c *Client) ExportUserData(ctx context.Context, id uint32) ([]byte, error) {
+	var resp *pb.ExportUserDataResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.ExportUserData(ctx, &pb.ExportUserDataRequest{Id: id})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Archive, nil
+}
+
+// EraseUser permanently removes the user with the given ID and its history,
+// and returns the receipt of the erasure. reason, such as a ticket reference,
+// is kept in the receipt.
+func (c *Client) EraseUser(ctx context.Context, id uint32, reason string) (*pb.ErasureReceipt, error) {
+	var resp *pb.EraseUserResponse
+	err := c.write(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.EraseUser(ctx, &pb.EraseUserRequest{Id: id, Reason: reason})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Receipt, nil
+}
+
+// RegisterAttribute registers a custom attribute, or replaces the definition
+// of a registered one, and returns the stored definition.
+func (c *Client) RegisterAttribute(ctx context.Context, def *pb.AttributeDefinition) (*pb.AttributeDefinition, error) {
+	var resp *pb.RegisterAttributeResponse
+	err := c.write(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.RegisterAttribute(ctx, &pb.RegisterAttributeRequest{Definition: def})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Definition, nil
+}
+
+// ListAttributes returns every registered attribute ordered by key.
+func (c *Client) ListAttributes(ctx context.Context) ([]*pb.AttributeDefinition, error) {
+	var resp *pb.ListAttributesResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.ListAttributes(ctx, &pb.ListAttributesRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Definitions, nil
+}
+
+// DeleteAttribute unregisters an attribute. It fails with ErrAttributeInUse
+// while any user has a value for it.
+func (c *Client) DeleteAttribute(ctx context.Context, key string) error {
+	return c.write(ctx, func(ctx context.Context) error {
+		_, err := c.rpc.DeleteAttribute(ctx, &pb.DeleteAttributeRequest{Key: key})
+		return err
+	})
+}
+
+// FindUniqueViolations returns the values of fields, or of every field that
+// can be made unique when none are given, that more than one user holds.
+func (c *Client) FindUniqueViolations(ctx context.Context, fields ...string) ([]*pb.UniqueViolation, error) {
+	var resp *pb.FindUniqueViolationsResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.rpc.FindUniqueViolations(ctx, &pb.FindUniqueViolationsRequest{Fields: fields})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Violations, nil
+}
+
+// Watcher receives user change events.
+type Watcher struct {
+	stream pb.UserService_WatchUsersClient
+}
+
+// Recv blocks until the next event. It returns io.EOF when the server ends
+// the stream, and an *Error otherwise.
+func (w *Watcher) Recv() (*pb.UserEvent, error) {
+	event, err := w.stream.Recv()
+	if err == io.EOF {
+		return nil, err
+	}
+	return event, fromStatus(err)
+}
+
+// WatchUsers streams changes to the users with the given IDs, or to all users
+// when ids is empty, until ctx is cancelled. No default deadline is applied.
+func (c *Client) WatchUsers(ctx context.Context, ids []uint32) (*Watcher, error) {
+	stream, err := c.rpc.WatchUsers(c.outgoing(ctx), &pb.WatchUsersRequest{Ids: ids})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &Watcher{stream: stream}, nil
+}
+
+// BatchSize is the number of users CreateUsers sends per stream message.
+const BatchSize = 500
+
+// CreateResult is the outcome of creating one user with CreateUsers.
+type CreateResult struct {
+	// ID is the ID assigned to the created user.
+	ID uint32
+	// Err reports why the user was not created.
+	Err error
+}
+
+// CreateUsers creates users over a single stream and returns one result per
+// user, in order. Invalid users are reported in their result and do not stop
+// the others from being created. No default deadline is applied.
+func (c *Client) CreateUsers(ctx context.Context, users []*pb.User) ([]CreateResult, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.BatchCreateUsers(c.outgoing(ctx))
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	// Sending runs alongside receiving so neither side stalls on flow control
+	sendErr := make(chan error, 1)
+	go func() {
+		for start := 0; start < len(users); start += BatchSize {
+			end := min(start+BatchSize, len(users))
+			if err := stream.Send(&pb.BatchCreateUsersRequest{Users: users[start:end]}); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	results := make([]CreateResult, len(users))
+	for received := 0; received < len(users); {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("userclient: stream ended after %d of %d results", received, len(users))
+		}
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		for _, r := range resp.Results {
+			if int(r.Index) >= len(users) {
+				return nil, fmt.Errorf("userclient: result for unknown user %d", r.Index)
+			}
+			if msg := r.GetError(); msg != "" {
+				results[r.Index].Err = reasonError(codes.InvalidArgument, r.Reason, msg)
+			} else {
+				results[r.Index].ID = r.GetId()
+			}
+			received++
+		}
+	}
+	if err := <-sendErr; err != nil && err != io.EOF {
+		return nil, fromStatus(err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		return nil, fromStatus(err)
+	}
+	return results, nil
+}
+
+// ImportOptions control how ImportUsers writes rows.
+type ImportOptions struct {
+	// Upsert replaces users whose ID already exists instead of failing the row.
+	Upsert bool
+	// DryRun validates every row and reports what would change without writing.
+	DryRun bool
+}
+
+// Importer sends the rows of an import.
+type Importer struct {
+	stream pb.UserService_ImportUsersClient
+	opts   ImportOptions
+	sent   bool
+}
+
+// Send sends one user read from the given line of the source file. Rows that
+// fail validation are reported by Close and do not stop the import.
+func (i *Importer) Send(line uint32, user *pb.User) error {
+	req := &pb.ImportUsersRequest{Line: line, User: user}
+	if !i.sent {
+		if i.opts.Upsert {
+			req.Mode = pb.ImportUsersRequest_UPSERT
+		}
+		req.DryRun = i.opts.DryRun
+		i.sent = true
+	}
+
+	err := i.stream.Send(req)
+	if err == io.EOF {
+		// The server ended the stream; its status explains why
+		_, err = i.stream.CloseAndRecv()
+	}
+	return fromStatus(err)
+}
+
+// Close ends the import and returns the per-row results.
+func (i *Importer) Close() (*pb.ImportUsersResponse, error) {
+	resp, err := i.stream.CloseAndRecv()
+	return resp, fromStatus(err)
+}
+
+// ImportUsers starts streaming users to the server. No default deadline is
+// applied, since imports may run for a long time.
+func (c *Client) ImportUsers(ctx context.Context, opts ImportOptions) (*Importer, error) {
+	stream, err := c.rpc.ImportUsers(c.outgoing(ctx))
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &Importer{stream: stream, opts: opts}, nil
+}
+
+// Exporter receives exported users.
+type Exporter struct {
+	stream pb.UserService_ExportUsersClient
+}
+
+// Recv returns the next user ordered by ID. It returns io.EOF after the last
+// user, and an *Error otherwise.
+func (e *Exporter) Recv() (*pb.User, error) {
+	user, err := e.stream.Recv()
+	if err == io.EOF {
+		return nil, err
+	}
+	return user, fromStatus(err)
+}
+
+// ExportUsers streams every user from the server. No default deadline is
+// applied, since exports may run for a long time.
+func (c *Client) ExportUsers(ctx context.Context) (*Exporter, error) {
+	stream, err := c.rpc.ExportUsers(c.outgoing(ctx), &pb.ExportUsersRequest{})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &Exporter{stream: stream}, nil
+}
+
+// outgoing adds the request ID, credentials and tenant to the call metadata.
+func (c *Client) outgoing(ctx context.Context) context.Context {
+	if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(userapi.RequestIDHeader)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, userapi.RequestIDHeader, newID())
+	}
+	if c.opts.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.opts.token)
+	}
+	if c.opts.tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, userapi.TenantHeader, c.opts.tenant)
+	}
+	return ctx
+}
+
+// call runs attempt with the client's metadata, deadline and retry policy.
+// Every attempt of a call shares one request ID.
+func (c *Client) call(ctx context.Context, attempt func(ctx context.Context) error) error {
+	ctx = c.outgoing(ctx)
+
+	var err error
+	for n := 1; ; n++ {
+		err = c.attempt(ctx, attempt)
+		if status.Code(err) != codes.Unavailable || n >= c.opts.maxAttempts {
+			return fromStatus(err)
+		}
+
+		timer := time.NewTimer(c.backoff(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fromStatus(err)
+		case <-timer.C:
+		}
+	}
+}
+
+// write is call for write RPCs. Every attempt also shares one idempotency
+// key, so the server applies the write once even when a response is lost.
+func (c *Client) write(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(userapi.IdempotencyKeyHeader)) == 0 {
+		ctx = WithIdempotencyKey(ctx, newID())
+	}
+	return c.call(ctx, attempt)
+}
+
+// WithIdempotencyKey returns a context making write calls send key instead
+// of a generated one, so a write can be retried safely across client
+// restarts. Use a new key for every distinct write.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, userapi.IdempotencyKeyHeader, key)
+}
+
+// newID returns a random 128-bit hex ID for request IDs and idempotency keys.
+func newID() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c *Client) attempt(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if _, ok := ctx.Deadline(); !ok && c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+	return attempt(ctx)
+}
+
+// backoff returns a random delay in [0, min(maxBackoff, baseBackoff*2^(n-1))).
+func (c *Client) backoff(n int) time.Duration {
+	d := c.opts.baseBackoff << (n - 1)
+	if d <= 0 || d > c.opts.maxBackoff {
+		d = c.opts.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
@@ -0,0 +1,405 @@
+package userclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	serviceerrors "user-service-module/internal/errors"
+	"user-service-module/internal/gdpr"
+	"user-service-module/internal/grpctest"
+	"user-service-module/internal/idempotency"
+	"user-service-module/internal/server"
+	"user-service-module/internal/tenant"
+	"user-service-module/pkg/userapi"
+	pb "user-service-module/proto/user/userpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func dial(t *testing.T, srv pb.UserServiceServer, interceptors ...grpc.UnaryServerInterceptor) *grpc.ClientConn {
+	t.Helper()
+	interceptors = append(interceptors, serviceerrors.UnaryServerInterceptor())
+	return grpctest.Dial(t, srv, grpc.ChainUnaryInterceptor(interceptors...))
+}
+
+func TestClient(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+	ctx := context.Background()
+
+	user, err := client.GetUser(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Steve", user.Fname)
+
+	users, err := client.ListUsers(ctx, []uint32{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	users, err = client.SearchUsers(ctx, SearchQuery{City: "LA"})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	user.City = "SF"
+	updated, err := client.UpdateUser(ctx, user, "city")
+	assert.NoError(t, err)
+	assert.Equal(t, user.Version+1, updated.Version)
+	_, err = client.UpdateUser(ctx, user, "city")
+	assert.ErrorIs(t, err, ErrVersionMismatch, "the read version is stale after the update")
+	assert.NoError(t, client.DeleteUserAtVersion(ctx, 1, updated.Version))
+}
+
+func TestClientErrors(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr error
+		code        codes.Code
+	}{
+		{
+			name:        "should return typed error for invalid ID",
+			call:        func() error { _, err := client.GetUser(ctx, 0); return err },
+			expectedErr: ErrInvalidID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "should return typed error for missing user",
+			call:        func() error { _, err := client.ListUsers(ctx, []uint32{1, 999}); return err },
+			expectedErr: ErrUserNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name:        "should return typed error for invalid search",
+			call:        func() error { _, err := client.SearchUsers(ctx, SearchQuery{City: "123"}); return err },
+			expectedErr: ErrInvalidFields,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "should return typed error for stale update",
+			call:        func() error { _, err := client.UpdateUser(ctx, &pb.User{Id: 1, Version: 5}); return err },
+			expectedErr: ErrVersionMismatch,
+			code:        codes.FailedPrecondition,
+		},
+		{
+			name:        "should return typed error for stale delete",
+			call:        func() error { return client.DeleteUserAtVersion(ctx, 2, 9) },
+			expectedErr: ErrVersionMismatch,
+			code:        codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.ErrorIs(t, err, tt.expectedErr)
+			var clientErr *Error
+			if assert.True(t, errors.As(err, &clientErr)) {
+				assert.Equal(t, tt.code, clientErr.Code)
+			}
+		})
+	}
+}
+
+func TestClientErrorReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"should classify by reason", serviceerrors.ToStatus(fmt.Errorf("%w: 1 event dropped", serviceerrors.ErrWatchOverflow)), ErrWatchOverflow},
+		{"should classify rate limits", serviceerrors.WithReason(status.New(codes.ResourceExhausted, "slow down"), userapi.ReasonRateLimited), ErrRateLimited},
+		{"should classify overload as a rate limit", serviceerrors.WithReason(status.New(codes.ResourceExhausted, "busy"), userapi.ReasonOverloaded), ErrRateLimited},
+		{"should not parse messages", status.Error(codes.NotFound, ErrAttributeNotFound.Error()), nil},
+		{"should fall back on codes with one meaning", status.Error(codes.PermissionDenied, "no"), ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				return nil, tt.err
+			}
+			_, err := NewFromConn(dial(t, server.NewUserServer(), fail)).GetUser(context.Background(), 1)
+
+			var clientErr *Error
+			if assert.ErrorAs(t, err, &clientErr) {
+				assert.Equal(t, status.Code(tt.err), clientErr.Code)
+				assert.Equal(t, tt.want, errors.Unwrap(clientErr))
+			}
+		})
+	}
+}
+
+func TestClientHistory(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+	ctx := context.Background()
+
+	before := time.Now()
+	user, err := client.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+	assert.NoError(t, err)
+	user.City = "Boston"
+	_, err = client.UpdateUser(ctx, user, "city")
+	assert.NoError(t, err)
+
+	revisions, next, err := client.GetUserHistory(ctx, user.Id, 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	assert.NotEmpty(t, next)
+	revisions, next, err = client.GetUserHistory(ctx, user.Id, 1, next)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 1) {
+		assert.Equal(t, "Boston", revisions[0].User.City)
+	}
+	assert.Empty(t, next)
+
+	revision, err := client.GetUserAsOf(ctx, user.Id, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), revision.User.Version)
+	_, err = client.GetUserAsOf(ctx, user.Id, before)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClientUserData(t *testing.T) {
+	userServer := server.NewUserServer()
+	client := NewFromConn(dial(t, userServer))
+	ctx := context.Background()
+
+	archive, err := client.ExportUserData(ctx, 2)
+	assert.NoError(t, err)
+	manifest, err := gdpr.Verify(archive, userServer.ArchivePublicKey())
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), manifest.UserID)
+
+	receipt, err := client.EraseUser(ctx, 2, "ticket 42")
+	assert.NoError(t, err)
+	assert.Equal(t, "ticket 42", receipt.Reason)
+	_, err = client.GetUser(ctx, 2)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = client.ExportUserData(ctx, 2)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClientAttributes(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+	ctx := context.Background()
+
+	team := &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_ENUM, Values: []string{"core", "growth"}, Indexed: true}
+	_, err := client.RegisterAttribute(ctx, team)
+	assert.NoError(t, err)
+	defs, err := client.ListAttributes(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, defs, 1) {
+		assert.Equal(t, "team", defs[0].Key)
+	}
+
+	_, err = client.UpdateUser(ctx, &pb.User{Id: 2, Attributes: map[string]string{"team": "core"}}, "attributes.team")
+	assert.NoError(t, err)
+	users, err := client.SearchUsers(ctx, SearchQuery{Attributes: map[string]string{"team": "core"}})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, uint32(2), users[0].Id)
+	}
+
+	err = client.DeleteAttribute(ctx, "team")
+	assert.ErrorIs(t, err, ErrAttributeInUse)
+	err = client.DeleteAttribute(ctx, "level")
+	assert.ErrorIs(t, err, ErrAttributeNotFound)
+}
+
+func TestClientUniqueFields(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer(server.WithUniqueFields("phone"))))
+	ctx := context.Background()
+
+	_, err := client.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9876543210", Height: 5.4})
+	assert.ErrorIs(t, err, ErrUniqueViolation)
+	var clientErr *Error
+	if assert.ErrorAs(t, err, &clientErr) {
+		assert.Equal(t, codes.AlreadyExists, clientErr.Code)
+		assert.Contains(t, clientErr.Message, "taken by user 2")
+	}
+
+	violations, err := client.FindUniqueViolations(ctx, "phone")
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestClientTenants(t *testing.T) {
+	tenants := server.NewTenants(func(name string) *server.UserServer {
+		return server.NewUserServer(server.WithTenant(name), server.WithMaxUsers(4), server.WithoutSampleUsers())
+	})
+	conn := dial(t, tenants, tenant.NewResolver(nil, nil).UnaryServerInterceptor())
+	acme := NewFromConn(conn, WithTenant("acme"))
+	globex := NewFromConn(conn, WithTenant("globex"))
+	ctx := context.Background()
+
+	user, err := acme.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+	assert.NoError(t, err)
+	_, err = globex.GetUser(ctx, user.Id)
+	assert.ErrorIs(t, err, ErrUserNotFound, "users should not be visible to other tenants")
+
+	for i := 0; i < 3; i++ {
+		_, err = acme.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+		assert.NoError(t, err)
+	}
+	_, err = acme.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = globex.CreateUser(ctx, &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+	assert.NoError(t, err, "quotas should apply per tenant")
+
+	_, err = NewFromConn(conn, WithTenant("Not A Tenant")).GetUser(ctx, 1)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+func TestClientImportExport(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+	ctx := context.Background()
+
+	importer, err := client.ImportUsers(ctx, ImportOptions{Upsert: true})
+	assert.NoError(t, err)
+	assert.NoError(t, importer.Send(2, &pb.User{Id: 1, Fname: "Steve", City: "Boston", Phone: "9827329211", Height: 5.8}))
+	assert.NoError(t, importer.Send(3, &pb.User{Fname: "Carol", City: "SF", Phone: "123", Height: 5.4}))
+	assert.NoError(t, importer.Send(4, &pb.User{Fname: "Dave", City: "SF", Phone: "9000000001", Height: 6}))
+	resp, err := importer.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), resp.Created)
+	assert.Equal(t, uint32(1), resp.Updated)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, uint32(3), resp.Errors[0].Line)
+		assert.Equal(t, "error: invalid field(s): phone", resp.Errors[0].Error)
+	}
+
+	exporter, err := client.ExportUsers(ctx)
+	assert.NoError(t, err)
+	var cities []string
+	for {
+		user, err := exporter.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		cities = append(cities, user.City)
+	}
+	assert.Equal(t, []string{"Boston", "NY", "LA", "SF"}, cities)
+}
+
+func TestClientCreateUsers(t *testing.T) {
+	client := NewFromConn(dial(t, server.NewUserServer()))
+
+	users := make([]*pb.User, BatchSize+2)
+	for i := range users {
+		users[i] = &pb.User{Fname: "Bulk", City: "SF", Phone: "9123456789", Height: 5.4}
+	}
+	users[1] = &pb.User{Fname: "Eve", City: "SF", Phone: "123", Height: 5}
+
+	results, err := client.CreateUsers(context.Background(), users)
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, len(users)) {
+		assert.Equal(t, CreateResult{ID: 4}, results[0])
+		assert.ErrorIs(t, results[1].Err, ErrInvalidFields)
+		assert.Equal(t, CreateResult{ID: 5}, results[2])
+		assert.Equal(t, CreateResult{ID: uint32(len(users) + 2)}, results[len(users)-1])
+	}
+}
+
+type flakyServer struct {
+	pb.UnimplementedUserServiceServer
+	failures int
+	calls    int
+}
+
+func (s *flakyServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &pb.GetUserResponse{StatusCode: 200, User: &pb.User{Id: req.Id}}, nil
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Run("should retry Unavailable", func(t *testing.T) {
+		srv := &flakyServer{failures: 2}
+		client := NewFromConn(dial(t, srv), WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+		user, err := client.GetUser(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(7), user.Id)
+		assert.Equal(t, 3, srv.calls)
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		srv := &flakyServer{failures: 5}
+		client := NewFromConn(dial(t, srv), WithRetry(2, time.Millisecond, 5*time.Millisecond))
+
+		_, err := client.GetUser(context.Background(), 7)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, 2, srv.calls)
+	})
+
+	t.Run("should write once when a response is lost", func(t *testing.T) {
+		lost := false
+		loseFirst := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			resp, err := handler(ctx, req)
+			if !lost {
+				lost = true
+				return nil, status.Error(codes.Unavailable, "connection reset")
+			}
+			return resp, err
+		}
+		store := idempotency.NewStore(time.Minute, idempotency.DefaultMethods)
+		conn := dial(t, server.NewUserServer(), loseFirst, store.UnaryServerInterceptor())
+		client := NewFromConn(conn, WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+		user, err := client.CreateUser(context.Background(), &pb.User{Fname: "Carol", City: "SF", Phone: "9123456789", Height: 5.4})
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(4), user.Id)
+		_, err = client.GetUser(context.Background(), 5)
+		assert.ErrorIs(t, err, ErrUserNotFound, "the retry must not create a second user")
+	})
+
+	t.Run("should replay an attribute deletion whose response is lost", func(t *testing.T) {
+		lost := false
+		loseFirst := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			resp, err := handler(ctx, req)
+			if !lost && info.FullMethod == pb.UserService_DeleteAttribute_FullMethodName {
+				lost = true
+				return nil, status.Error(codes.Unavailable, "connection reset")
+			}
+			return resp, err
+		}
+		store := idempotency.NewStore(time.Minute, idempotency.DefaultMethods)
+		conn := dial(t, server.NewUserServer(), loseFirst, store.UnaryServerInterceptor())
+		client := NewFromConn(conn, WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+		_, err := client.RegisterAttribute(context.Background(), &pb.AttributeDefinition{Key: "team", Type: pb.AttributeDefinition_STRING})
+		assert.NoError(t, err)
+		err = client.DeleteAttribute(context.Background(), "team")
+		assert.NoError(t, err, "the retry should get the first response instead of ErrAttributeNotFound")
+		assert.True(t, lost)
+	})
+
+	t.Run("should reject a key reused for another write", func(t *testing.T) {
+		store := idempotency.NewStore(time.Minute, idempotency.DefaultMethods)
+		client := NewFromConn(dial(t, server.NewUserServer(), store.UnaryServerInterceptor()))
+		ctx := WithIdempotencyKey(context.Background(), "k1")
+
+		_, err := client.UpdateUser(ctx, &pb.User{Id: 1, City: "SF"}, "city")
+		assert.NoError(t, err)
+		_, err = client.UpdateUser(ctx, &pb.User{Id: 1, City: "NY"}, "city")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		client := NewFromConn(dial(t, server.NewUserServer()), WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+		_, err := client.GetUser(context.Background(), 999)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
@@ -0,0 +1,126 @@
+package userclient
+
+import (
+	"errors"
+	"fmt"
+
+	"user-service-module/pkg/userapi"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sentinel errors matched with errors.Is. Their messages are the ones the
+// server starts its error messages with.
+var (
+	ErrInvalidID            = errors.New("error: invalid ID(s)")
+	ErrUserNotFound         = errors.New("error: user(s) not found")
+	ErrInvalidFields        = errors.New("error: invalid field(s)")
+	ErrUnauthenticated      = errors.New("error: unauthenticated")
+	ErrPermissionDenied     = errors.New("error: permission denied")
+	ErrUserExists           = errors.New("error: user(s) already exist")
+	ErrVersionMismatch      = errors.New("error: version mismatch")
+	ErrIdempotencyKeyReused = errors.New("error: idempotency key reused with a different request")
+	ErrQuotaExceeded        = errors.New("error: user quota exceeded")
+	ErrAttributeNotFound    = errors.New("error: attribute(s) not registered")
+	ErrAttributeInUse       = errors.New("error: attribute in use")
+	ErrUniqueViolation      = errors.New("error: unique constraint violated")
+	ErrWatchOverflow        = errors.New("error: watcher fell too far behind")
+	ErrRateLimited          = errors.New("error: rate limited")
+	ErrUnavailable          = errors.New("error: service unavailable")
+	ErrInternal             = errors.New("error: internal server error")
+)
+
+// kinds maps the ErrorInfo reasons sent by the server to sentinel errors.
+var kinds = map[string]error{
+	userapi.ReasonInvalidID:            ErrInvalidID,
+	userapi.ReasonInvalidFields:        ErrInvalidFields,
+	userapi.ReasonUserNotFound:         ErrUserNotFound,
+	userapi.ReasonUserExists:           ErrUserExists,
+	userapi.ReasonVersionMismatch:      ErrVersionMismatch,
+	userapi.ReasonUnauthenticated:      ErrUnauthenticated,
+	userapi.ReasonPermissionDenied:     ErrPermissionDenied,
+	userapi.ReasonIdempotencyKeyReused: ErrIdempotencyKeyReused,
+	userapi.ReasonQuotaExceeded:        ErrQuotaExceeded,
+	userapi.ReasonAttributeNotFound:    ErrAttributeNotFound,
+	userapi.ReasonAttributeInUse:       ErrAttributeInUse,
+	userapi.ReasonUniqueViolation:      ErrUniqueViolation,
+	userapi.ReasonWatchOverflow:        ErrWatchOverflow,
+	userapi.ReasonRateLimited:          ErrRateLimited,
+	userapi.ReasonOverloaded:           ErrRateLimited,
+}
+
+// Error is returned by every Client method when the call fails.
+type Error struct {
+	// Code is the gRPC status code returned by the server.
+	Code codes.Code
+	// Message is the server's error message.
+	Message string
+	// RequestID identifies the call in server logs, when the server sent one.
+	RequestID string
+
+	kind error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("userclient: %s: %s", e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request_id=" + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error matching the failure, if any.
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// fromStatus converts an error returned by the generated client into an *Error.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.RequestInfo:
+			e.RequestID = detail.RequestId
+		case *errdetails.ErrorInfo:
+			if detail.Domain == userapi.ErrorDomain {
+				e.kind = kinds[detail.Reason]
+			}
+		}
+	}
+	if e.kind == nil {
+		e.kind = kindOf(st.Code())
+	}
+	return e
+}
+
+// kindOf returns the sentinel error of failures without a known reason,
+// for the codes that have a single meaning.
+func kindOf(code codes.Code) error {
+	switch code {
+	case codes.Unauthenticated:
+		return ErrUnauthenticated
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.Unavailable:
+		return ErrUnavailable
+	case codes.Internal:
+		return ErrInternal
+	}
+	return nil
+}
+
+// reasonError returns the error the server would send for a failure with
+// code and reason, for failures reported outside the call status.
+func reasonError(code codes.Code, reason, msg string) error {
+	st := status.New(code, msg)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: userapi.ErrorDomain}); err == nil {
+		st = withInfo
+	}
+	return fromStatus(st.Err())
+}
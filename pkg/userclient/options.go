@@ -0,0 +1,85 @@
+package userclient
+
+import (
+	"crypto/tls"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Defaults applied by New unless overridden with options.
+const (
+	DefaultTimeout     = 5 * time.Second
+	DefaultMaxAttempts = 3
+	DefaultBaseBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+)
+
+type options struct {
+	timeout     time.Duration
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	token       string
+	tenant      string
+	creds       credentials.TransportCredentials
+	dialOptions []grpc.DialOption
+}
+
+func defaultOptions() options {
+	return options{
+		timeout:     DefaultTimeout,
+		maxAttempts: DefaultMaxAttempts,
+		baseBackoff: DefaultBaseBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		creds:       insecure.NewCredentials(),
+	}
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+// WithTimeout sets the deadline applied to each attempt when the caller's
+// context has none. Zero disables the default deadline.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRetry sets how many attempts are made for calls failing with
+// Unavailable, and the bounds of the jittered exponential backoff between them.
+func WithRetry(maxAttempts int, baseBackoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		o.maxAttempts = maxAttempts
+		o.baseBackoff = baseBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// WithoutRetry makes every call a single attempt.
+func WithoutRetry() Option {
+	return func(o *options) { o.maxAttempts = 1 }
+}
+
+// WithToken sends token as a bearer token with every call.
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithTenant makes every call act for the named tenant. Callers whose token is
+// bound to a tenant act for it without naming it.
+func WithTenant(name string) Option {
+	return func(o *options) { o.tenant = name }
+}
+
+// WithTLS connects over TLS using cfg. Connections are insecure by default.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) { o.creds = credentials.NewTLS(cfg) }
+}
+
+// WithDialOptions appends extra options used when New dials the server, such
+// as additional interceptors.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOptions = append(o.dialOptions, opts...) }
+}